@@ -0,0 +1,59 @@
+package vast
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Raw returns the parameters as a string, un-escaping them if they are
+// XML-encoded.
+func (p *AdParameters) Raw() string {
+	if p.XMLEncoded {
+		return html.UnescapeString(p.Parameters)
+	}
+	return p.Parameters
+}
+
+// SetRaw sets the parameters to s, escaping it if the parameters are
+// XML-encoded.
+func (p *AdParameters) SetRaw(s string) {
+	if p.XMLEncoded {
+		s = html.EscapeString(s)
+	}
+	p.Parameters = s
+}
+
+// DecodeJSON decodes the JSON parameters into v.
+func (p *AdParameters) DecodeJSON(v interface{}) error {
+	if err := json.Unmarshal([]byte(strings.TrimSpace(p.Raw())), v); err != nil {
+		return fmt.Errorf("vast: invalid JSON ad parameters: %v", err)
+	}
+	return nil
+}
+
+// EncodeJSON sets the parameters to the JSON encoding of v.
+func (p *AdParameters) EncodeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("vast: cannot encode ad parameters: %v", err)
+	}
+	p.SetRaw(string(b))
+	return nil
+}
+
+// Values decodes the URL-encoded (key1=value1&key2=value2) parameters.
+func (p *AdParameters) Values() (url.Values, error) {
+	v, err := url.ParseQuery(strings.TrimSpace(p.Raw()))
+	if err != nil {
+		return nil, fmt.Errorf("vast: invalid URL-encoded ad parameters: %v", err)
+	}
+	return v, nil
+}
+
+// SetValues sets the parameters to the URL encoding of v, sorted by key.
+func (p *AdParameters) SetValues(v url.Values) {
+	p.SetRaw(v.Encode())
+}
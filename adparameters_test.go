@@ -0,0 +1,66 @@
+package vast
+
+import (
+	"encoding/xml"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdParametersJSON(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/spotx_vpaid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := v.Ads[0].InLine.Creatives[0].Linear.AdParameters
+	var params map[string]interface{}
+	if !assert.NoError(t, p.DecodeJSON(&params)) {
+		return
+	}
+	assert.Equal(t, "1130507-1818483", params["ad_id"])
+
+	params["title"] = "rewritten"
+	if assert.NoError(t, p.EncodeJSON(params)) {
+		var params2 map[string]interface{}
+		assert.NoError(t, p.DecodeJSON(&params2))
+		assert.Equal(t, params, params2)
+	}
+
+	assert.Error(t, (&AdParameters{Parameters: "a=b"}).DecodeJSON(&params))
+	assert.Error(t, p.EncodeJSON(func() {}))
+}
+
+func TestAdParametersXMLEncoded(t *testing.T) {
+	var p AdParameters
+	err := xml.Unmarshal([]byte(`<AdParameters xmlEncoded="true">&lt;params&gt;&amp;quot;x&amp;quot; &amp;amp; y&lt;/params&gt;</AdParameters>`), &p)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `<params>&quot;x&quot; &amp; y</params>`, p.Parameters)
+	assert.Equal(t, `<params>"x" & y</params>`, p.Raw())
+
+	p.SetRaw(`{"a":"<b>"}`)
+	assert.Equal(t, `{&#34;a&#34;:&#34;&lt;b&gt;&#34;}`, p.Parameters)
+	assert.Equal(t, `{"a":"<b>"}`, p.Raw())
+	var m map[string]string
+	if assert.NoError(t, p.DecodeJSON(&m)) {
+		assert.Equal(t, map[string]string{"a": "<b>"}, m)
+	}
+}
+
+func TestAdParametersValues(t *testing.T) {
+	p := &AdParameters{Parameters: "\n id=1&name=a%20b \n"}
+	v, err := p.Values()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, url.Values{"id": {"1"}, "name": {"a b"}}, v)
+
+	v.Set("id", "2")
+	p.SetValues(v)
+	assert.Equal(t, "id=2&name=a+b", p.Parameters)
+
+	_, err = (&AdParameters{Parameters: "a=%zz"}).Values()
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package vast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Ad types of the adType attribute of an Ad (VAST 4.1).
+const (
+	AdTypeVideo  = "video"
+	AdTypeAudio  = "audio"
+	AdTypeHybrid = "hybrid"
+)
+
+// AudioTypes lists the MIME types of the audio files commonly served in audio
+// ads, to be used as the Types of a MediaSelector.
+var AudioTypes = []string{"audio/mpeg", "audio/mp4", "audio/aac", "audio/ogg", "audio/wav", "audio/webm"}
+
+// IsAudio reports whether the ad is an audio ad, either declared as such by
+// its adType attribute or, as DAAST ads are, an inline ad whose linear
+// creatives only have audio media files.
+func (ad *Ad) IsAudio() bool {
+	if ad.AdType != "" {
+		return strings.EqualFold(strings.TrimSpace(ad.AdType), AdTypeAudio)
+	}
+	if ad.InLine == nil {
+		return false
+	}
+	found := false
+	for _, c := range ad.InLine.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		for _, m := range c.Linear.MediaFiles {
+			if !m.IsAudio() {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// IsAudio reports whether the media file is an audio file, as told by its
+// MIME type.
+func (m MediaFile) IsAudio() bool {
+	return strings.HasPrefix(strings.ToLower(mimeType(m.Type)), "audio/")
+}
+
+// Validate checks that the media file has the attributes required by the
+// spec: a URI, a delivery method, a type and, unless it is an audio file, its
+// dimensions.
+func (m MediaFile) Validate() error {
+	return m.validate(m.IsAudio())
+}
+
+// ValidateMediaFiles validates the media files of the linear creatives of an
+// inline ad. The dimensions of the media files of an audio ad are not
+// required.
+func (ad *Ad) ValidateMediaFiles() error {
+	if ad.InLine == nil {
+		return nil
+	}
+	audio := ad.IsAudio()
+	for _, c := range ad.InLine.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		for _, m := range c.Linear.MediaFiles {
+			if err := m.validate(audio || m.IsAudio()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m MediaFile) validate(audio bool) error {
+	var missing string
+	switch {
+	case strings.TrimSpace(m.URI) == "":
+		missing = "URI"
+	case strings.TrimSpace(m.Delivery) == "":
+		missing = "delivery"
+	case strings.TrimSpace(m.Type) == "":
+		missing = "type"
+	case !audio && m.Width <= 0:
+		missing = "width"
+	case !audio && m.Height <= 0:
+		missing = "height"
+	default:
+		return nil
+	}
+	return &Error{Code: ErrorCodeSchemaValidation, Message: fmt.Sprintf("media file without %s", missing)}
+}
+
+// The DAAST 1.0 audio ad documents share the structure of VAST 3 documents,
+// with a <DAAST> root element, except for a few renamed elements. The
+// UnmarshalXML methods below decode them into the same model.
+
+// UnmarshalXML implements xml.Unmarshaler interface, decoding the DAAST
+// <DAASTAdTagURI> element as the VASTAdTagURI.
+func (w *Wrapper) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type wrapper Wrapper
+	w2 := struct {
+		wrapper
+		DAASTAdTagURI *CDATAString `xml:",omitempty"`
+	}{wrapper: wrapper(*w)}
+	if err := dec.DecodeElement(&w2, &start); err != nil {
+		return err
+	}
+	*w = Wrapper(w2.wrapper)
+	mergeAdTagURI(&w.VASTAdTagURI, w2.DAASTAdTagURI)
+	return nil
+}
+
+// UnmarshalXML implements xml.Unmarshaler interface, decoding the DAAST
+// <AudioInteractions> element as VideoClicks.
+func (l *Linear) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type linear Linear
+	l2 := struct {
+		linear
+		AudioInteractions *VideoClicks `xml:",omitempty"`
+	}{linear: linear(*l)}
+	if err := dec.DecodeElement(&l2, &start); err != nil {
+		return err
+	}
+	*l = Linear(l2.linear)
+	mergeClicks(&l.VideoClicks, l2.AudioInteractions)
+	return nil
+}
+
+// UnmarshalXML implements xml.Unmarshaler interface, decoding the DAAST
+// <AudioInteractions> element as VideoClicks.
+func (l *LinearWrapper) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type linearWrapper LinearWrapper
+	l2 := struct {
+		linearWrapper
+		AudioInteractions *VideoClicks `xml:",omitempty"`
+	}{linearWrapper: linearWrapper(*l)}
+	if err := dec.DecodeElement(&l2, &start); err != nil {
+		return err
+	}
+	*l = LinearWrapper(l2.linearWrapper)
+	mergeClicks(&l.VideoClicks, l2.AudioInteractions)
+	return nil
+}
+
+// mergeAdTagURI sets the ad tag URI of a wrapper to the DAAST one, unless the
+// wrapper has a VAST one.
+func mergeAdTagURI(dst *CDATAString, daast *CDATAString) {
+	if daast != nil && dst.CDATA == "" {
+		*dst = *daast
+	}
+}
+
+// mergeClicks appends the clicks of the DAAST audio interactions to the video
+// clicks.
+func mergeClicks(dst **VideoClicks, audio *VideoClicks) {
+	if audio == nil {
+		return
+	}
+	if *dst == nil {
+		*dst = audio
+		return
+	}
+	(*dst).ClickThroughs = append((*dst).ClickThroughs, audio.ClickThroughs...)
+	(*dst).ClickTrackings = append((*dst).ClickTrackings, audio.ClickTrackings...)
+	(*dst).CustomClicks = append((*dst).CustomClicks, audio.CustomClicks...)
+}
@@ -0,0 +1,199 @@
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDAASTInline(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/daast_inline_audio.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "1.0", v.Version)
+	if !assert.Len(t, v.Ads, 1) {
+		return
+	}
+	ad := v.Ads[0]
+	assert.True(t, ad.IsAudio())
+	assert.NoError(t, ad.ValidateMediaFiles())
+	if !assert.NotNil(t, ad.InLine) {
+		return
+	}
+	assert.Equal(t, []Category{{Code: "IAB1"}}, ad.InLine.Categories)
+	assert.Equal(t, 3600, ad.InLine.Expires)
+	if assert.Len(t, ad.InLine.Creatives, 2) {
+		l := ad.InLine.Creatives[0].Linear
+		if assert.NotNil(t, l) && assert.NotNil(t, l.VideoClicks) {
+			assert.Equal(t, []VideoClick{{URI: "http://example.com/landing"}}, l.VideoClicks.ClickThroughs)
+			assert.Equal(t, []VideoClick{{URI: "http://example.com/click"}}, l.VideoClicks.ClickTrackings)
+		}
+		c := ad.InLine.Creatives[1].CompanionAds
+		if assert.NotNil(t, c) && assert.Len(t, c.Companions, 1) {
+			assert.Equal(t, 300, c.Companions[0].Width)
+			if assert.NotNil(t, c.Companions[0].StaticResource) {
+				assert.Equal(t, "http://example.com/banner.png", c.Companions[0].StaticResource.URI)
+			}
+		}
+	}
+	assert.Equal(t, []string{"http://example.com/click"}, ad.ClickTrackingURLs())
+
+	m, ok := MediaSelector{Audio: true}.Select(ad.InLine.Creatives[0].Linear.MediaFiles)
+	if assert.True(t, ok) {
+		assert.Equal(t, "mp3", m.ID)
+	}
+	_, ok = MediaSelector{}.Select(ad.InLine.Creatives[0].Linear.MediaFiles)
+	assert.False(t, ok)
+}
+
+func TestDAASTWrapper(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/daast_wrapper_audio.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, v.Ads, 1) && assert.NotNil(t, v.Ads[0].Wrapper) {
+		w := v.Ads[0].Wrapper
+		assert.Equal(t, "http://example.com/daast.xml", w.VASTAdTagURI.CDATA)
+		if assert.Len(t, w.Creatives, 1) && assert.NotNil(t, w.Creatives[0].Linear) {
+			assert.Equal(t, &VideoClicks{ClickTrackings: []VideoClick{{URI: "http://example.com/wrapper/click"}}}, w.Creatives[0].Linear.VideoClicks)
+		}
+	}
+}
+
+func TestDAASTDecodeBytes(t *testing.T) {
+	for _, f := range []string{"testdata/daast_inline_audio.xml", "testdata/daast_wrapper_audio.xml"} {
+		b, err := ioutil.ReadFile(f)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		var want VAST
+		if !assert.NoError(t, xml.Unmarshal(b, &want), f) {
+			continue
+		}
+		got, err := DecodeBytes(b, DecodeOptions{})
+		if assert.NoError(t, err, f) {
+			assert.Equal(t, &want, got, f)
+		}
+	}
+	// VAST elements take precedence over their DAAST counterparts
+	doc := `<DAAST><Ad><Wrapper><DAASTAdTagURI>http://daast</DAASTAdTagURI><VASTAdTagURI>http://vast</VASTAdTagURI>` +
+		`<Creatives><Creative><Linear><VideoClicks><ClickThrough>http://video</ClickThrough></VideoClicks>` +
+		`<AudioInteractions><ClickThrough>http://audio</ClickThrough></AudioInteractions></Linear></Creative></Creatives>` +
+		`</Wrapper></Ad></DAAST>`
+	var want VAST
+	if assert.NoError(t, xml.Unmarshal([]byte(doc), &want)) {
+		w := want.Ads[0].Wrapper
+		assert.Equal(t, "http://vast", w.VASTAdTagURI.CDATA)
+		assert.Equal(t, []VideoClick{{URI: "http://video"}, {URI: "http://audio"}}, w.Creatives[0].Linear.VideoClicks.ClickThroughs)
+		got, err := DecodeBytes([]byte(doc), DecodeOptions{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, &want, got)
+		}
+	}
+}
+
+func TestDAASTEncode(t *testing.T) {
+	for _, f := range []string{"testdata/daast_inline_audio.xml", "testdata/daast_wrapper_audio.xml"} {
+		b, err := ioutil.ReadFile(f)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		v, err := DecodeBytes(b, DecodeOptions{})
+		if !assert.NoError(t, err, f) {
+			continue
+		}
+		var buf bytes.Buffer
+		if !assert.NoError(t, Encode(&buf, v, EncodeOptions{Indent: "  "}), f) {
+			continue
+		}
+		res := buf.String()
+		assert.Contains(t, res, `<DAAST version="1.0">`, f)
+		assert.NotContains(t, res, "VideoClicks", f)
+		assert.NotContains(t, res, "VASTAdTagURI", f)
+		assert.NotContains(t, res, "width=\"0\"", f)
+		got, err := DecodeBytes(buf.Bytes(), DecodeOptions{})
+		if assert.NoError(t, err, f) {
+			assert.Empty(t, Diff(v, got), f)
+		}
+	}
+
+	v, _, _, err := loadFixture("testdata/daast_inline_audio.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	if assert.NoError(t, Encode(&buf, v, EncodeOptions{Minify: true})) {
+		res := buf.String()
+		assert.Contains(t, res, `<Ad id="daast-1" sequence="1">`)
+		assert.Contains(t, res, `<AdTitle>Radio spot</AdTitle><Category>IAB1</Category>`)
+		assert.Contains(t, res, `</Creatives><Expires>3600</Expires></InLine>`)
+		assert.Contains(t, res, `<MediaFile id="mp3" delivery="progressive" type="audio/mpeg" bitrate="128">`)
+		assert.Contains(t, res, `</MediaFiles><AudioInteractions><ClickThrough>`)
+	}
+
+	// a target version converts the document to VAST
+	buf.Reset()
+	if assert.NoError(t, Encode(&buf, v, EncodeOptions{Version: "4.1", Minify: true})) {
+		res := buf.String()
+		assert.Contains(t, res, `<VAST version="4.1">`)
+		assert.Contains(t, res, `<VideoClicks>`)
+		assert.NotContains(t, res, "DAAST")
+	}
+}
+
+func TestVASTAudio(t *testing.T) {
+	v, _, res, err := loadFixture("testdata/vast4_audio.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Len(t, v.Ads, 1) {
+		return
+	}
+	ad := v.Ads[0]
+	assert.Equal(t, AdTypeAudio, ad.AdType)
+	assert.True(t, ad.IsAudio())
+	if assert.NotNil(t, ad.InLine) {
+		assert.Equal(t, []Category{{Authority: "https://www.iabtechlab.com/categoryauthority", Code: "IAB1-1"}}, ad.InLine.Categories)
+	}
+	assert.Contains(t, res, `adType="audio"`)
+	assert.Contains(t, res, `<Category authority="https://www.iabtechlab.com/categoryauthority"><![CDATA[IAB1-1]]></Category>`)
+
+	m, ok := MediaSelector{Types: AudioTypes}.Select(ad.InLine.Creatives[0].Linear.MediaFiles)
+	if assert.True(t, ok) {
+		assert.Equal(t, "https://example.com/ad.mp3", m.URI)
+	}
+}
+
+func TestAdIsAudio(t *testing.T) {
+	audio := &Linear{MediaFiles: []MediaFile{{Type: "audio/mpeg"}}}
+	video := &Linear{MediaFiles: []MediaFile{{Type: "video/mp4"}}}
+	assert.True(t, (&Ad{AdType: "Audio"}).IsAudio())
+	assert.False(t, (&Ad{AdType: AdTypeHybrid, InLine: &InLine{Creatives: []Creative{{Linear: audio}}}}).IsAudio())
+	assert.True(t, (&Ad{InLine: &InLine{Creatives: []Creative{{Linear: audio}}}}).IsAudio())
+	assert.False(t, (&Ad{InLine: &InLine{Creatives: []Creative{{Linear: audio}, {Linear: video}}}}).IsAudio())
+	assert.False(t, (&Ad{InLine: &InLine{}}).IsAudio())
+	assert.False(t, (&Ad{Wrapper: &Wrapper{}}).IsAudio())
+}
+
+func TestMediaFileValidate(t *testing.T) {
+	assert.NoError(t, MediaFile{Delivery: "progressive", Type: "video/mp4", Width: 640, Height: 360, URI: "http://a"}.Validate())
+	assert.NoError(t, MediaFile{Delivery: "progressive", Type: "audio/mpeg", URI: "http://a"}.Validate())
+	assert.EqualError(t, MediaFile{Delivery: "progressive", Type: "video/mp4", Width: 640, URI: "http://a"}.Validate(),
+		"vast: error 101: media file without height")
+	assert.EqualError(t, MediaFile{Type: "audio/mpeg", URI: "http://a"}.Validate(),
+		"vast: error 101: media file without delivery")
+
+	// media files of audio ads need no dimensions, whatever their type
+	ad := &Ad{AdType: AdTypeAudio, InLine: &InLine{Creatives: []Creative{{Linear: &Linear{
+		MediaFiles: []MediaFile{{Delivery: "streaming", Type: "application/x-mpegURL", URI: "http://a"}},
+	}}}}}
+	assert.NoError(t, ad.ValidateMediaFiles())
+	ad.AdType = AdTypeVideo
+	if err := ad.ValidateMediaFiles(); assert.Error(t, err) {
+		assert.Equal(t, ErrorCodeSchemaValidation, err.(*Error).Code)
+	}
+}
@@ -0,0 +1,421 @@
+// Package beacon fires VAST tracking pixels (impressions, tracking events,
+// clicks, errors...) from a bounded pool of workers.
+//
+// URLs are macro expanded before being fired, identical URLs may be
+// de-duplicated, requests to a given host can be rate limited and failed
+// requests are retried. The outcome of each beacon is reported through a
+// callback and to an optional vast.Observer.
+package beacon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/vast"
+)
+
+// ErrClosed is returned by Fire when the dispatcher has been closed.
+var ErrClosed = errors.New("beacon: dispatcher closed")
+
+// Beacon is a tracking pixel to fire.
+type Beacon struct {
+	// URL of the pixel, possibly containing VAST macros.
+	URL string
+	// Macros to expand in URL. The CACHEBUSTING and TIMESTAMP macros are
+	// generated by the dispatcher when not provided.
+	Macros vast.Macros
+	// UserAgent is the user agent of the viewer, forwarded when the beacon is
+	// fired on its behalf (server side). If empty, Config.UserAgent is used.
+	UserAgent string
+	// ForwardedFor is the IP address of the viewer, sent in the
+	// X-Forwarded-For header when the beacon is fired on its behalf.
+	ForwardedFor string
+}
+
+// Result is the outcome of a fired beacon.
+type Result struct {
+	Beacon Beacon
+	// URL is the expanded URL that has been requested.
+	URL string
+	// StatusCode is the HTTP status of the last attempt, 0 if no response
+	// was received.
+	StatusCode int
+	// Attempts is the number of requests made.
+	Attempts int
+	// Duplicate is true if the beacon was not fired because the same URL was
+	// fired recently.
+	Duplicate bool
+	// Duration is the time spent firing the beacon, retries included.
+	Duration time.Duration
+	// Err is the error of the last attempt, nil on success.
+	Err error
+}
+
+// Config defines the behavior of a Dispatcher.
+type Config struct {
+	// Client is the HTTP client used to fire beacons. http.DefaultClient is
+	// used if nil.
+	Client *http.Client
+	// Workers is the number of beacons fired concurrently. Defaults to 10.
+	Workers int
+	// QueueSize is the number of beacons waiting for a worker before Fire
+	// blocks. Defaults to 100.
+	QueueSize int
+	// Timeout of each request. Defaults to 5 seconds.
+	Timeout time.Duration
+	// Retries is the number of extra attempts made for beacons failing with a
+	// network error or a 5xx status code.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// subsequent retry. Defaults to 100ms.
+	RetryBackoff time.Duration
+	// NoRedirectHosts lists the hosts for which redirects must not be
+	// followed; the redirect response is considered the beacon response.
+	NoRedirectHosts []string
+	// UserAgent sent with beacons not providing one.
+	UserAgent string
+	// DedupWindow is the period during which a URL fired once is not fired
+	// again. De-duplication is disabled if zero.
+	DedupWindow time.Duration
+	// DedupSize is the maximum number of URLs remembered for de-duplication,
+	// the oldest being forgotten first. Defaults to 10000.
+	DedupSize int
+	// HostRate is the maximum number of requests per second sent to a single
+	// host. Not limited if zero.
+	HostRate float64
+	// OnResult is called from the worker goroutine with the result of each
+	// beacon.
+	OnResult func(Result)
+	// Observer, if not nil, is notified of the result of each beacon.
+	Observer vast.Observer
+}
+
+// Dispatcher fires beacons using a bounded pool of workers.
+type Dispatcher struct {
+	c          Config
+	client     *http.Client
+	noRedirect map[string]bool
+	jobs       chan job
+	wg         sync.WaitGroup
+
+	// closeMu protects closed and the jobs channel from being closed while
+	// beacons are being queued.
+	closeMu sync.RWMutex
+	closed  bool
+	// done is closed by Close to release the beacons blocked on a full
+	// queue, which hold closeMu.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// fired lists the URLs in seen by firing time, to expire them without
+	// scanning seen.
+	fired []firedURL
+	// next is the time of the next request allowed to each host, and pruned
+	// the last time the hosts with a time past were forgotten.
+	next   map[string]time.Time
+	pruned time.Time
+}
+
+type firedURL struct {
+	url string
+	at  time.Time
+}
+
+type job struct {
+	ctx context.Context
+	b   Beacon
+}
+
+// New creates a Dispatcher and starts its workers.
+func New(c Config) *Dispatcher {
+	if c.Workers <= 0 {
+		c.Workers = 10
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 100 * time.Millisecond
+	}
+	if c.DedupSize <= 0 {
+		c.DedupSize = 10000
+	}
+	d := &Dispatcher{
+		c:          c,
+		noRedirect: map[string]bool{},
+		jobs:       make(chan job, c.QueueSize),
+		done:       make(chan struct{}),
+		seen:       map[string]time.Time{},
+		next:       map[string]time.Time{},
+	}
+	client := http.DefaultClient
+	if c.Client != nil {
+		client = c.Client
+	}
+	for _, h := range c.NoRedirectHosts {
+		d.noRedirect[h] = true
+	}
+	if len(d.noRedirect) > 0 {
+		// copy the client to change its redirect policy
+		cl := *client
+		check := client.CheckRedirect
+		cl.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if d.noRedirect[via[0].URL.Hostname()] {
+				return http.ErrUseLastResponse
+			}
+			if check != nil {
+				return check(req, via)
+			}
+			// the default policy of http.Client
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		client = &cl
+	}
+	d.client = client
+	d.wg.Add(c.Workers)
+	for i := 0; i < c.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Fire queues beacons to be fired. It blocks while the queue is full, until
+// ctx is done or the dispatcher is closed. The context is also used to cancel the beacons still queued or
+// in flight.
+func (d *Dispatcher) Fire(ctx context.Context, beacons ...Beacon) error {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return ErrClosed
+	}
+	for _, b := range beacons {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case d.jobs <- job{ctx: ctx, b: b}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-d.done:
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// FireURLs queues the given URLs with the same macros. It is a shortcut for
+// Fire with beacons made of urls.
+func (d *Dispatcher) FireURLs(ctx context.Context, m vast.Macros, urls ...string) error {
+	beacons := make([]Beacon, 0, len(urls))
+	for _, u := range urls {
+		beacons = append(beacons, Beacon{URL: u, Macros: m})
+	}
+	return d.Fire(ctx, beacons...)
+}
+
+// Close stops accepting new beacons and waits for the queued ones to be
+// fired.
+func (d *Dispatcher) Close() {
+	d.closeOnce.Do(func() { close(d.done) })
+	d.closeMu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.jobs)
+	}
+	d.closeMu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for j := range d.jobs {
+		r := d.fire(j.ctx, j.b)
+		if d.c.OnResult != nil {
+			d.c.OnResult(r)
+		}
+		if d.c.Observer != nil {
+			d.c.Observer.OnBeacon(vast.BeaconEvent{
+				URL:       r.URL,
+				Status:    r.StatusCode,
+				Attempts:  r.Attempts,
+				Duplicate: r.Duplicate,
+				Duration:  r.Duration,
+				Err:       r.Err,
+			})
+		}
+	}
+}
+
+func (d *Dispatcher) fire(ctx context.Context, b Beacon) (r Result) {
+	start := time.Now()
+	r = Result{Beacon: b, URL: d.expand(b)}
+	defer func() { r.Duration = time.Since(start) }()
+
+	if err := ctx.Err(); err != nil {
+		r.Err = err
+		return r
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	// generated macros are left out of the de-duplication key
+	if d.duplicate(b.Macros.Expand(b.URL), start) {
+		r.Duplicate = true
+		return r
+	}
+	backoff := d.c.RetryBackoff
+	for {
+		if err := d.wait(ctx, u.Hostname()); err != nil {
+			r.Err = err
+			return r
+		}
+		r.Attempts++
+		r.StatusCode, r.Err = d.do(ctx, r.URL, b)
+		if r.Attempts > d.c.Retries || !retryable(r.StatusCode, r.Err) {
+			return r
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			r.Err = ctx.Err()
+			return r
+		}
+		backoff *= 2
+	}
+}
+
+// expand returns the URL of the beacon with its macros expanded.
+func (d *Dispatcher) expand(b Beacon) string {
+	m := vast.Macros{
+		"CACHEBUSTING": fmt.Sprintf("%08d", rand.Intn(100000000)),
+		"TIMESTAMP":    time.Now().Format("2006-01-02T15:04:05.000-07:00"),
+	}
+	for k, v := range b.Macros {
+		m[k] = v
+	}
+	return m.Expand(b.URL)
+}
+
+// duplicate reports whether the beacon identified by u has been fired during
+// the dedup window and records it as fired otherwise.
+func (d *Dispatcher) duplicate(u string, now time.Time) bool {
+	if d.c.DedupWindow <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.seen[u]; ok && now.Sub(t) < d.c.DedupWindow {
+		return true
+	}
+	// forget about the expired URLs, and the oldest ones if too many are
+	// remembered
+	for len(d.fired) > 0 && (now.Sub(d.fired[0].at) >= d.c.DedupWindow || len(d.seen) >= d.c.DedupSize) {
+		f := d.fired[0]
+		d.fired[0] = firedURL{}
+		d.fired = d.fired[1:]
+		// the URL may have been fired again since it expired
+		if t, ok := d.seen[f.url]; ok && t.Equal(f.at) {
+			delete(d.seen, f.url)
+		}
+	}
+	d.seen[u] = now
+	d.fired = append(d.fired, firedURL{u, now})
+	return false
+}
+
+// wait blocks until a request can be sent to host according to HostRate.
+func (d *Dispatcher) wait(ctx context.Context, host string) error {
+	if d.c.HostRate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if delay := d.reserve(host, now).Sub(now); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// reserve returns the time at which a request can be sent to host, now or
+// later, and reserves it.
+func (d *Dispatcher) reserve(host string, now time.Time) time.Time {
+	interval := time.Duration(float64(time.Second) / d.c.HostRate)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// forget about the hosts which can be requested right away, at most once
+	// per second not to scan the map on each request
+	if now.Sub(d.pruned) >= time.Second {
+		for h, at := range d.next {
+			if !at.After(now) {
+				delete(d.next, h)
+			}
+		}
+		d.pruned = now
+	}
+	at := d.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	d.next[host] = at.Add(interval)
+	return at
+}
+
+func (d *Dispatcher) do(ctx context.Context, u string, b Beacon) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.c.Timeout)
+	defer cancel()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	ua := b.UserAgent
+	if ua == "" {
+		ua = d.c.UserAgent
+	}
+	if ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	if b.ForwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", b.ForwardedFor)
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode >= 400 {
+		return res.StatusCode, fmt.Errorf("beacon: unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt should be retried.
+func retryable(status int, err error) bool {
+	if err == nil {
+		return false
+	}
+	return status == 0 || status >= 500
+}
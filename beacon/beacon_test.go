@@ -0,0 +1,262 @@
+package beacon
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/vast"
+	"github.com/rs/vast/vasttest"
+	"github.com/stretchr/testify/assert"
+)
+
+// collect returns an OnResult callback storing the results in rs.
+func collect(mu *sync.Mutex, rs *[]Result) func(Result) {
+	return func(r Result) {
+		mu.Lock()
+		*rs = append(*rs, r)
+		mu.Unlock()
+	}
+}
+
+func TestDispatcherFire(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+
+	var mu sync.Mutex
+	var rs []Result
+	d := New(Config{Workers: 2, OnResult: collect(&mu, &rs), UserAgent: "test-agent"})
+	ad := vast.Ad{InLine: &vast.InLine{
+		Impressions: []vast.Impression{{URI: s.TrackURL("imp")}},
+		Errors:      []vast.CDATAString{{CDATA: s.TrackURL("error?code=[ERRORCODE]&cb=[CACHEBUSTING]")}},
+	}}
+	assert.NoError(t, d.FireURLs(context.Background(), nil, ad.ImpressionURLs()...))
+	assert.NoError(t, d.FireURLs(context.Background(), vast.Macros{"ERRORCODE": "303", "CACHEBUSTING": "1234"}, ad.ErrorURLs()...))
+	assert.NoError(t, d.Fire(context.Background(), Beacon{URL: s.TrackURL("fwd"), UserAgent: "viewer-agent", ForwardedFor: "1.2.3.4"}))
+	d.Close()
+
+	assert.True(t, s.Fired(s.TrackURL("imp")))
+	assert.True(t, s.Fired(s.TrackURL("error?code=303&cb=1234")))
+	for _, h := range s.Hits() {
+		switch h.URL {
+		case "/track/imp":
+			assert.Equal(t, "test-agent", h.Header.Get("User-Agent"))
+		case "/track/fwd":
+			assert.Equal(t, "viewer-agent", h.Header.Get("User-Agent"))
+			assert.Equal(t, "1.2.3.4", h.Header.Get("X-Forwarded-For"))
+		}
+	}
+	if assert.Len(t, rs, 3) {
+		for _, r := range rs {
+			assert.NoError(t, r.Err)
+			assert.Equal(t, http.StatusOK, r.StatusCode)
+			assert.Equal(t, 1, r.Attempts)
+		}
+	}
+	assert.Equal(t, ErrClosed, d.Fire(context.Background(), Beacon{URL: s.TrackURL("late")}))
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleStatus("/fail", http.StatusServiceUnavailable)
+	s.HandleStatus("/notfound", http.StatusNotFound)
+
+	var mu sync.Mutex
+	var rs []Result
+	d := New(Config{Workers: 1, Retries: 2, RetryBackoff: time.Millisecond, OnResult: collect(&mu, &rs)})
+	assert.NoError(t, d.FireURLs(context.Background(), nil, s.URLFor("/fail"), s.URLFor("/notfound")))
+	d.Close()
+
+	assert.Equal(t, 3, s.HitCount("/fail"))
+	assert.Equal(t, 1, s.HitCount("/notfound"))
+	if assert.Len(t, rs, 2) {
+		assert.Error(t, rs[0].Err)
+		assert.Equal(t, 3, rs[0].Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, rs[0].StatusCode)
+		assert.Equal(t, 1, rs[1].Attempts)
+	}
+}
+
+func TestDispatcherNoRedirect(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleRedirect("/redirect", vasttest.TrackPrefix+"target", http.StatusFound)
+
+	var mu sync.Mutex
+	var rs []Result
+	u, _ := url.Parse(s.URL)
+	d := New(Config{NoRedirectHosts: []string{u.Hostname()}, OnResult: collect(&mu, &rs)})
+	assert.NoError(t, d.FireURLs(context.Background(), nil, s.URLFor("/redirect")))
+	d.Close()
+
+	assert.True(t, s.Fired("/redirect"))
+	assert.False(t, s.Fired("/track/target"))
+	if assert.Len(t, rs, 1) {
+		assert.Equal(t, http.StatusFound, rs[0].StatusCode)
+		assert.NoError(t, rs[0].Err)
+	}
+}
+
+func TestDispatcherRedirectLoop(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleRedirect("/loop", "/loop", http.StatusFound)
+
+	var mu sync.Mutex
+	var rs []Result
+	d := New(Config{NoRedirectHosts: []string{"other.example.com"}, OnResult: collect(&mu, &rs)})
+	assert.NoError(t, d.FireURLs(context.Background(), nil, s.URLFor("/loop")))
+	d.Close()
+
+	if assert.Len(t, rs, 1) {
+		assert.EqualError(t, rs[0].Err, `Get "/loop": stopped after 10 redirects`)
+	}
+}
+
+func TestDispatcherCloseBlockedFire(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleTimeout("/timeout")
+
+	d := New(Config{Workers: 1, QueueSize: 1, Timeout: 50 * time.Millisecond})
+	fired := make(chan error)
+	go func() {
+		// the first beacon is fired, the second queued, the third blocks
+		fired <- d.FireURLs(context.Background(), nil, s.URLFor("/timeout"), s.URLFor("/timeout"), s.URLFor("/timeout"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case err := <-fired:
+		assert.Equal(t, ErrClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("Fire still blocked after Close")
+	}
+	<-closed
+}
+
+func TestDispatcherDedup(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+
+	var mu sync.Mutex
+	var rs []Result
+	d := New(Config{Workers: 1, DedupWindow: time.Minute, OnResult: collect(&mu, &rs)})
+	u := s.TrackURL("imp?cb=[CACHEBUSTING]")
+	assert.NoError(t, d.FireURLs(context.Background(), nil, u, u, s.TrackURL("other")))
+	d.Close()
+
+	assert.Len(t, s.Hits(), 2)
+	if assert.Len(t, rs, 3) {
+		assert.False(t, rs[0].Duplicate)
+		assert.True(t, rs[1].Duplicate)
+		assert.Equal(t, 0, rs[1].Attempts)
+		assert.False(t, rs[2].Duplicate)
+	}
+}
+
+func TestDispatcherHostRate(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+
+	d := New(Config{Workers: 3, HostRate: 50})
+	start := time.Now()
+	assert.NoError(t, d.FireURLs(context.Background(), nil, s.TrackURL("1"), s.TrackURL("2"), s.TrackURL("3")))
+	d.Close()
+
+	// 3 requests at 50 req/s need at least 2 intervals of 20ms
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+	assert.Len(t, s.Hits(), 3)
+}
+
+func TestDispatcherCancel(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleTimeout("/timeout")
+
+	var mu sync.Mutex
+	var rs []Result
+	d := New(Config{Workers: 1, OnResult: collect(&mu, &rs)})
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, d.FireURLs(ctx, nil, s.URLFor("/timeout"), s.TrackURL("queued")))
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, d.Fire(ctx, Beacon{URL: s.TrackURL("late")}))
+	d.Close()
+
+	assert.False(t, s.Fired("/track/queued"))
+	if assert.Len(t, rs, 2) {
+		assert.Error(t, rs[0].Err)
+		assert.Equal(t, context.Canceled, rs[1].Err)
+		assert.Equal(t, 0, rs[1].Attempts)
+	}
+}
+
+type beaconObserver struct {
+	vast.NopObserver
+	mu     sync.Mutex
+	events []vast.BeaconEvent
+}
+
+func (o *beaconObserver) OnBeacon(e vast.BeaconEvent) {
+	o.mu.Lock()
+	o.events = append(o.events, e)
+	o.mu.Unlock()
+}
+
+func TestDispatcherObserver(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+
+	o := &beaconObserver{}
+	d := New(Config{Workers: 1, Observer: o})
+	assert.NoError(t, d.FireURLs(context.Background(), vast.Macros{"ID": "1"}, s.TrackURL("imp?id=[ID]")))
+	d.Close()
+	if assert.Len(t, o.events, 1) {
+		assert.Equal(t, s.TrackURL("imp?id=1"), o.events[0].URL)
+		assert.Equal(t, http.StatusOK, o.events[0].Status)
+		assert.Equal(t, 1, o.events[0].Attempts)
+		assert.NoError(t, o.events[0].Err)
+	}
+}
+
+func TestDispatcherDedupExpiry(t *testing.T) {
+	d := New(Config{DedupWindow: time.Minute, DedupSize: 2})
+	defer d.Close()
+
+	now := time.Now()
+	assert.False(t, d.duplicate("a", now))
+	assert.True(t, d.duplicate("a", now.Add(time.Second)))
+	assert.False(t, d.duplicate("b", now.Add(2*time.Second)))
+	// the map is full, a is forgotten to remember c
+	assert.False(t, d.duplicate("c", now.Add(3*time.Second)))
+	assert.Len(t, d.seen, 2)
+	assert.False(t, d.duplicate("a", now.Add(4*time.Second)))
+	// b and c expire, a is fired again
+	later := now.Add(time.Minute + 3*time.Second)
+	assert.False(t, d.duplicate("b", later))
+	assert.Equal(t, map[string]time.Time{"a": now.Add(4 * time.Second), "b": later}, d.seen)
+	assert.Len(t, d.fired, 2)
+}
+
+func TestDispatcherHostRatePrune(t *testing.T) {
+	d := New(Config{HostRate: 10})
+	defer d.Close()
+
+	now := time.Now()
+	assert.Equal(t, now, d.reserve("a", now))
+	assert.Equal(t, now.Add(100*time.Millisecond), d.reserve("a", now))
+	assert.Equal(t, now, d.reserve("b", now))
+	// a and b can be requested right away again, they are forgotten
+	later := now.Add(2 * time.Second)
+	assert.Equal(t, later, d.reserve("c", later))
+	assert.Len(t, d.next, 1)
+}
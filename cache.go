@@ -0,0 +1,310 @@
+package vast
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxCacheBodySize is the size of the largest response cached by a
+// Cache with no MaxBodySize.
+const DefaultMaxCacheBodySize = 256 << 10
+
+// DefaultCacheFetchTimeout is the time budget of the fetches of a Cache with
+// no FetchTimeout.
+const DefaultCacheFetchTimeout = 10 * time.Second
+
+// CacheBackend stores the cached ad tag responses. Implementations must be
+// safe for concurrent use.
+type CacheBackend interface {
+	// Get returns the value stored for key if it has not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value for key for the ttl duration.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// Cache caches the responses of ad tags, keyed by their expanded URL.
+//
+// The lifetime of a response is given by its Cache-Control (max-age and
+// s-maxage) or Expires headers and by the VAST 4 Expires element of its inline
+// ads, the shortest one being used. Responses marked as no-store, no-cache or
+// private are not cached. Concurrent fetches of the same ad tag are
+// de-duplicated: only one request is sent and its response is shared.
+type Cache struct {
+	// Backend stores the responses.
+	Backend CacheBackend
+	// MaxBodySize is the size of the largest response cached,
+	// DefaultMaxCacheBodySize if 0.
+	MaxBodySize int
+	// DefaultTTL is the lifetime of responses with no caching information.
+	// Such responses are not cached if 0.
+	DefaultTTL time.Duration
+	// MaxTTL, if not 0, caps the lifetime of responses.
+	MaxTTL time.Duration
+	// FetchTimeout is the time budget of a fetch shared by concurrent
+	// callers, DefaultCacheFetchTimeout if 0.
+	FetchTimeout time.Duration
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// NewCache creates a cache storing responses in backend.
+func NewCache(backend CacheBackend) *Cache {
+	return &Cache{Backend: backend}
+}
+
+// flight is an in-progress fetch shared by concurrent callers.
+type flight struct {
+	done chan struct{}
+	body []byte
+	err  error
+	// number of callers waiting for the fetch, guarded by the cache mutex
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// fetchFunc fetches the response of an ad tag and returns its body and
+// lifetime.
+type fetchFunc func(ctx context.Context) ([]byte, time.Duration, error)
+
+// get returns the cached response for key, or calls fetch and caches its
+// result if it has a lifetime. Concurrent calls for the same key wait for the
+// first one to complete and share its result. The returned boolean is true if
+// the response was fetched for this call.
+//
+// The shared fetch runs with a context detached from the one of the caller
+// starting it, so that caller giving up doesn't fail the others: each caller
+// only stops waiting when its own context is done. The fetch is bounded by
+// FetchTimeout and canceled once all its callers gave up, the next caller
+// starting a new one.
+func (c *Cache) get(ctx context.Context, key string, fetch fetchFunc) ([]byte, bool, error) {
+	if b, ok := c.Backend.Get(ctx, key); ok {
+		return b, false, nil
+	}
+	c.mu.Lock()
+	f, shared := c.flights[key]
+	if !shared {
+		timeout := c.FetchTimeout
+		if timeout == 0 {
+			timeout = DefaultCacheFetchTimeout
+		}
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		if c.flights == nil {
+			c.flights = map[string]*flight{}
+		}
+		c.flights[key] = f
+		go c.run(fctx, key, f, fetch)
+	}
+	f.waiters++
+	c.mu.Unlock()
+	select {
+	case <-f.done:
+		return f.body, !shared, f.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+			c.release(key, f)
+		}
+		c.mu.Unlock()
+		return nil, false, ctx.Err()
+	}
+}
+
+// release removes the flight f of key, unless it was already replaced. The
+// cache mutex must be held.
+func (c *Cache) release(key string, f *flight) {
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+}
+
+// run calls fetch for the flight f of key, caches its result and releases
+// the callers waiting for it, even if fetch panics.
+func (c *Cache) run(ctx context.Context, key string, f *flight, fetch fetchFunc) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.body, f.err = nil, fmt.Errorf("vast: fetching %s panicked: %v", key, r)
+		}
+		f.cancel()
+		c.mu.Lock()
+		c.release(key, f)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	body, ttl, err := fetch(ctx)
+	f.body, f.err = body, err
+	if err != nil {
+		return
+	}
+	max := c.MaxBodySize
+	if max == 0 {
+		max = DefaultMaxCacheBodySize
+	}
+	if c.MaxTTL > 0 && ttl > c.MaxTTL {
+		ttl = c.MaxTTL
+	}
+	if ttl > 0 && len(body) <= max {
+		c.Backend.Set(ctx, key, body, ttl)
+	}
+}
+
+// ttl returns the lifetime of the response v with headers h received at now.
+func (c *Cache) ttl(h http.Header, v *VAST, now time.Time) time.Duration {
+	ttl, ok := headerTTL(h, now)
+	if ok && ttl <= 0 {
+		return 0
+	}
+	for _, ad := range v.Ads {
+		if ad.InLine == nil || ad.InLine.Expires <= 0 {
+			continue
+		}
+		if exp := time.Duration(ad.InLine.Expires) * time.Second; !ok || exp < ttl {
+			ttl, ok = exp, true
+		}
+	}
+	if !ok {
+		return c.DefaultTTL
+	}
+	return ttl
+}
+
+// headerTTL returns the lifetime of a response given by its caching headers,
+// and whether the headers defined it.
+func headerTTL(h http.Header, now time.Time) (time.Duration, bool) {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		maxAge, sMaxAge := -1, -1
+		for _, d := range strings.Split(cc, ",") {
+			name, value := strings.TrimSpace(d), ""
+			if i := strings.IndexByte(name, '='); i != -1 {
+				name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+			}
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache", "private":
+				return 0, true
+			case "max-age":
+				if n, err := strconv.Atoi(value); err == nil {
+					maxAge = n
+				}
+			case "s-maxage":
+				if n, err := strconv.Atoi(value); err == nil {
+					sMaxAge = n
+				}
+			}
+		}
+		if sMaxAge >= 0 {
+			return time.Duration(sMaxAge) * time.Second, true
+		}
+		if maxAge >= 0 {
+			return time.Duration(maxAge) * time.Second, true
+		}
+	}
+	if exp := h.Get("Expires"); exp != "" {
+		t, err := http.ParseTime(exp)
+		if err != nil {
+			// invalid dates represent a time in the past
+			return 0, true
+		}
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			now = d
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// MemoryCache is an in-memory CacheBackend evicting the least recently used
+// entries when its size limits are reached.
+type MemoryCache struct {
+	// MaxEntries is the maximum number of entries, unlimited if 0.
+	MaxEntries int
+	// MaxBytes is the maximum total size of the values, unlimited if 0.
+	MaxBytes int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	size  int
+	now   func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates a MemoryCache with the given limits.
+func NewMemoryCache(maxEntries, maxBytes int) *MemoryCache {
+	return &MemoryCache{MaxEntries: maxEntries, MaxBytes: maxBytes}
+}
+
+func (m *MemoryCache) init() {
+	if m.items == nil {
+		m.ll = list.New()
+		m.items = map[string]*list.Element{}
+	}
+	if m.now == nil {
+		m.now = time.Now
+	}
+}
+
+// Get implements the CacheBackend interface.
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := e.Value.(*memoryEntry)
+	if !m.now().Before(ent.expires) {
+		m.remove(e)
+		return nil, false
+	}
+	m.ll.MoveToFront(e)
+	return ent.value, true
+}
+
+// Set implements the CacheBackend interface.
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	if e, ok := m.items[key]; ok {
+		m.remove(e)
+	}
+	if m.MaxBytes > 0 && len(value) > m.MaxBytes {
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, value: value, expires: m.now().Add(ttl)})
+	m.size += len(value)
+	for (m.MaxEntries > 0 && m.ll.Len() > m.MaxEntries) || (m.MaxBytes > 0 && m.size > m.MaxBytes) {
+		m.remove(m.ll.Back())
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// not yet evicted.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ll == nil {
+		return 0
+	}
+	return m.ll.Len()
+}
+
+func (m *MemoryCache) remove(e *list.Element) {
+	ent := m.ll.Remove(e).(*memoryEntry)
+	delete(m.items, ent.key)
+	m.size -= len(ent.value)
+}
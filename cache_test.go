@@ -0,0 +1,243 @@
+package vast
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderTTL(t *testing.T) {
+	now := time.Date(2020, 1, 2, 15, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		header http.Header
+		ttl    time.Duration
+		ok     bool
+	}{
+		{http.Header{}, 0, false},
+		{http.Header{"Cache-Control": {"public, max-age=60"}}, time.Minute, true},
+		{http.Header{"Cache-Control": {"max-age=60, s-maxage=\"30\""}}, 30 * time.Second, true},
+		{http.Header{"Cache-Control": {"max-age=60, no-cache"}}, 0, true},
+		{http.Header{"Cache-Control": {"private"}}, 0, true},
+		{http.Header{"Cache-Control": {"public"}, "Expires": {"Thu, 02 Jan 2020 15:02:00 GMT"}}, 2 * time.Minute, true},
+		{http.Header{"Expires": {"Thu, 02 Jan 2020 15:02:00 GMT"}, "Date": {"Thu, 02 Jan 2020 15:01:00 GMT"}}, time.Minute, true},
+		{http.Header{"Expires": {"0"}}, 0, true},
+	} {
+		ttl, ok := headerTTL(tt.header, now)
+		assert.Equal(t, tt.ttl, ttl, "%v", tt.header)
+		assert.Equal(t, tt.ok, ok, "%v", tt.header)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	c := &Cache{DefaultTTL: time.Second}
+	v := &VAST{Ads: []Ad{{InLine: &InLine{Expires: 30}}, {InLine: &InLine{Expires: 20}}, {Wrapper: &Wrapper{}}}}
+	assert.Equal(t, 20*time.Second, c.ttl(http.Header{}, v, now))
+	assert.Equal(t, 10*time.Second, c.ttl(http.Header{"Cache-Control": {"max-age=10"}}, v, now))
+	assert.Equal(t, 20*time.Second, c.ttl(http.Header{"Cache-Control": {"max-age=60"}}, v, now))
+	assert.Equal(t, time.Duration(0), c.ttl(http.Header{"Cache-Control": {"no-store"}}, v, now))
+	assert.Equal(t, time.Second, c.ttl(http.Header{}, &VAST{}, now))
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemoryCache(2, 10)
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, "a", []byte("aaa"), time.Minute)
+	m.Set(ctx, "b", []byte("bbb"), time.Minute)
+	_, ok := m.Get(ctx, "a")
+	assert.True(t, ok)
+	m.Set(ctx, "c", []byte("ccc"), time.Minute)
+	_, ok = m.Get(ctx, "b")
+	assert.False(t, ok, "least recently used entry evicted")
+	assert.Equal(t, 2, m.Len())
+
+	m.Set(ctx, "d", []byte("dddddddd"), time.Minute)
+	_, ok = m.Get(ctx, "a")
+	assert.False(t, ok, "evicted by size")
+	b, ok := m.Get(ctx, "d")
+	assert.True(t, ok)
+	assert.Equal(t, "dddddddd", string(b))
+
+	m.Set(ctx, "big", make([]byte, 11), time.Minute)
+	_, ok = m.Get(ctx, "big")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = m.Get(ctx, "d")
+	assert.False(t, ok, "expired")
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c := NewCache(NewMemoryCache(0, 0))
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]byte, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("body"), time.Minute, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, _, err := c.get(context.Background(), "k", fetch)
+			assert.NoError(t, err)
+			assert.Equal(t, "body", string(b))
+		}()
+	}
+	for {
+		c.mu.Lock()
+		n := len(c.flights)
+		c.mu.Unlock()
+		if n == 1 && atomic.LoadInt32(&calls) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	b, leader, err := c.get(context.Background(), "k", fetch)
+	assert.NoError(t, err)
+	assert.False(t, leader)
+	assert.Equal(t, "body", string(b))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	c := &Cache{Backend: NewMemoryCache(0, 0), MaxBodySize: 2}
+	var calls int
+	fetch := func(context.Context) ([]byte, time.Duration, error) {
+		calls++
+		return []byte("body"), time.Minute, nil
+	}
+	c.get(context.Background(), "k", fetch)
+	c.get(context.Background(), "k", fetch)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheSingleflightCancelledLeader(t *testing.T) {
+	c := NewCache(NewMemoryCache(0, 0))
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) ([]byte, time.Duration, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		return []byte("body"), time.Minute, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, _, err := c.get(ctx, "k", fetch)
+		leaderErr <- err
+	}()
+	for {
+		c.mu.Lock()
+		n := len(c.flights)
+		c.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	followerErr := make(chan error)
+	follower := make(chan struct{})
+	go func() {
+		close(follower)
+		b, leader, err := c.get(context.Background(), "k", fetch)
+		assert.Equal(t, "body", string(b))
+		assert.False(t, leader)
+		followerErr <- err
+	}()
+	<-follower
+	// wait for the follower to join the flight
+	for {
+		c.mu.Lock()
+		n := c.flights["k"].waiters
+		c.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+	close(release)
+	assert.NoError(t, <-followerErr)
+	b, ok := c.Backend.Get(context.Background(), "k")
+	assert.True(t, ok)
+	assert.Equal(t, "body", string(b))
+}
+
+func TestCacheAbandonedFetch(t *testing.T) {
+	c := NewCache(NewMemoryCache(0, 0))
+	var calls int32
+	canceled := make(chan struct{}, 2)
+	fetch := func(ctx context.Context) ([]byte, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		canceled <- struct{}{}
+		return nil, 0, ctx.Err()
+	}
+	// the fetch is canceled when its last caller gives up
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, _, err := c.get(ctx, "k", fetch)
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, err)
+		<-canceled
+	}
+	// and a new one is started by the next caller
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// callers which never give up are bounded by the fetch timeout
+	c.FetchTimeout = 10 * time.Millisecond
+	_, _, err := c.get(context.Background(), "k", fetch)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	<-canceled
+	c.mu.Lock()
+	assert.Empty(t, c.flights)
+	c.mu.Unlock()
+}
+
+func TestResolverCacheHangingServer(t *testing.T) {
+	stop := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-stop:
+		case <-req.Context().Done():
+		}
+	}))
+	defer s.Close()
+	defer close(stop)
+
+	r := &Resolver{Cache: NewCache(NewMemoryCache(10, 0))}
+	r.Cache.FetchTimeout = 20 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		_, err := r.Fetch(context.Background(), s.URL)
+		if assert.Error(t, err) {
+			assert.Equal(t, ErrorCodeWrapperTimeout, err.(*Error).Code)
+		}
+	}
+}
+
+func TestCacheFetchPanic(t *testing.T) {
+	c := NewCache(NewMemoryCache(0, 0))
+	_, _, err := c.get(context.Background(), "k", func(context.Context) ([]byte, time.Duration, error) {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "vast: fetching k panicked: boom")
+	assert.Empty(t, c.flights)
+}
@@ -0,0 +1,133 @@
+package vast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MIME types of the common closed caption formats.
+const (
+	CaptionTypeWebVTT = "text/vtt"
+	CaptionTypeTTML   = "application/ttml+xml"
+	CaptionTypeSRT    = "application/x-subrip"
+)
+
+// CaptionSelector describes the closed captions a player is able to display,
+// and is used to pick the best closed caption file of a linear creative.
+type CaptionSelector struct {
+	// Languages lists the accepted languages by order of preference, as
+	// language tags such as "en" or "fr-CA". A language also matches the
+	// files of its variants and of its base language, e.g. "en" matches
+	// "en-US", exact matches being preferred. Any language is accepted if
+	// empty.
+	Languages []string
+	// Types lists the MIME types of the formats supported by the player by
+	// order of preference. Any type is accepted if empty.
+	Types []string
+}
+
+// Select returns the closed caption file in the most preferred language and,
+// among the files of that language, in the most preferred format. The first
+// file is returned in case of a tie. The returned boolean is false if no file
+// is accepted.
+func (s CaptionSelector) Select(files []ClosedCaptionFile) (ClosedCaptionFile, bool) {
+	best, bestLang, bestType := ClosedCaptionFile{}, -1, -1
+	for _, f := range files {
+		if strings.TrimSpace(f.URI) == "" {
+			continue
+		}
+		lang, typ := s.languageRank(f.Language), s.typeRank(f.Type)
+		if lang == -1 || typ == -1 {
+			continue
+		}
+		if bestLang == -1 || lang < bestLang || (lang == bestLang && typ < bestType) {
+			best, bestLang, bestType = f, lang, typ
+		}
+	}
+	return best, bestLang != -1
+}
+
+// languageRank returns the rank of the language l in the preferences of s,
+// lower is better, or -1 if it is not accepted.
+func (s CaptionSelector) languageRank(l string) int {
+	if len(s.Languages) == 0 {
+		return 0
+	}
+	l = normalizeLanguage(l)
+	for i, pref := range s.Languages {
+		pref = normalizeLanguage(pref)
+		switch {
+		case l == pref:
+			return 2 * i
+		case l != "" && baseLanguage(l) == baseLanguage(pref):
+			return 2*i + 1
+		}
+	}
+	return -1
+}
+
+// typeRank returns the rank of the MIME type t in the preferences of s, lower
+// is better, or -1 if it is not accepted.
+func (s CaptionSelector) typeRank(t string) int {
+	if len(s.Types) == 0 {
+		return 0
+	}
+	t = mimeType(t)
+	for i, typ := range s.Types {
+		if strings.EqualFold(t, typ) {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeLanguage returns the language tag l in lower case with hyphens as
+// separators, e.g. "en-us" for "en_US".
+func normalizeLanguage(l string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(l), "_", "-", -1))
+}
+
+// baseLanguage returns the primary language subtag of a normalized language
+// tag, e.g. "en" for "en-us".
+func baseLanguage(l string) string {
+	if i := strings.IndexByte(l, '-'); i != -1 {
+		return l[:i]
+	}
+	return l
+}
+
+// CaptionPolicy defines the closed captions an accessibility policy requires
+// from the linear creatives of the ads.
+type CaptionPolicy struct {
+	// Required requires closed captions. The policy is not enforced if false.
+	Required bool
+	// Selector defines the languages and formats of the required closed
+	// captions. Any closed caption file is enough if it is the zero value.
+	Selector CaptionSelector
+}
+
+// Check returns a warning for each linear creative of the inline ad which
+// does not have the closed captions required by the policy. Wrapper ads are
+// not checked, their creatives carrying no media file.
+func (p CaptionPolicy) Check(ad *Ad) []string {
+	if !p.Required || ad.InLine == nil {
+		return nil
+	}
+	var warnings []string
+	for i, c := range ad.InLine.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		name := c.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		switch _, ok := p.Selector.Select(c.Linear.ClosedCaptionFiles); {
+		case len(c.Linear.ClosedCaptionFiles) == 0:
+			warnings = append(warnings, fmt.Sprintf("creative %s has no closed captions", name))
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("creative %s has no closed captions in a required language and format", name))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,84 @@
+package vast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClosedCaptionFiles(t *testing.T) {
+	v, _, res, err := loadFixture("testdata/vast4_closed_captions.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, v.Ads, 1) && assert.NotNil(t, v.Ads[0].InLine) && assert.Len(t, v.Ads[0].InLine.Creatives, 1) {
+		l := v.Ads[0].InLine.Creatives[0].Linear
+		if assert.NotNil(t, l) {
+			assert.Equal(t, []ClosedCaptionFile{
+				{Type: CaptionTypeWebVTT, Language: "en", URI: "https://example.com/captions-en.vtt"},
+				{Type: CaptionTypeTTML, Language: "en-US", URI: "https://example.com/captions-en-us.ttml"},
+				{Type: CaptionTypeSRT, Language: "fr", URI: "https://example.com/captions-fr.srt"},
+			}, l.ClosedCaptionFiles)
+			assert.Len(t, l.MediaFiles, 1)
+		}
+	}
+	assert.Contains(t, res, `<ClosedCaptionFiles>`)
+
+	var b bytes.Buffer
+	if assert.NoError(t, Encode(&b, v, EncodeOptions{Version: "3.0"})) {
+		assert.NotContains(t, b.String(), "ClosedCaption")
+	}
+	b.Reset()
+	if assert.NoError(t, Encode(&b, v, EncodeOptions{})) {
+		assert.Contains(t, b.String(), `<MediaFiles><ClosedCaptionFiles><ClosedCaptionFile type="text/vtt" language="en">`)
+	}
+}
+
+func TestCaptionSelector(t *testing.T) {
+	files := []ClosedCaptionFile{
+		{Type: CaptionTypeWebVTT, Language: "en", URI: "en.vtt"},
+		{Type: CaptionTypeTTML, Language: "en-US", URI: "en-us.ttml"},
+		{Type: CaptionTypeSRT, Language: "fr", URI: "fr.srt"},
+		{Type: CaptionTypeWebVTT, Language: "de", URI: ""},
+		{Type: "text/vtt; charset=utf-8", Language: "ES_mx", URI: "es-mx.vtt"},
+	}
+	tests := []struct {
+		name string
+		s    CaptionSelector
+		want string
+	}{
+		{"any", CaptionSelector{}, "en.vtt"},
+		{"exact language", CaptionSelector{Languages: []string{"en-US"}}, "en-us.ttml"},
+		{"base language", CaptionSelector{Languages: []string{"en-GB"}}, "en.vtt"},
+		{"variant", CaptionSelector{Languages: []string{"fr-CA"}}, "fr.srt"},
+		{"language preference", CaptionSelector{Languages: []string{"it", "fr", "en"}}, "fr.srt"},
+		{"format preference", CaptionSelector{Languages: []string{"en"}, Types: []string{CaptionTypeTTML, CaptionTypeWebVTT}}, "en.vtt"},
+		{"format support", CaptionSelector{Languages: []string{"en"}, Types: []string{CaptionTypeTTML}}, "en-us.ttml"},
+		{"normalized", CaptionSelector{Languages: []string{"es-MX"}, Types: []string{CaptionTypeWebVTT}}, "es-mx.vtt"},
+		{"no uri", CaptionSelector{Languages: []string{"de"}}, ""},
+		{"no format", CaptionSelector{Languages: []string{"fr"}, Types: []string{CaptionTypeWebVTT}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := tt.s.Select(files)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, f.URI)
+		})
+	}
+}
+
+func TestCaptionPolicy(t *testing.T) {
+	ad := &Ad{InLine: &InLine{Creatives: []Creative{
+		{ID: "captioned", Linear: &Linear{ClosedCaptionFiles: []ClosedCaptionFile{{Type: CaptionTypeSRT, Language: "en", URI: "en.srt"}}}},
+		{Linear: &Linear{}},
+		{CompanionAds: &CompanionAds{}},
+	}}}
+	assert.Nil(t, CaptionPolicy{}.Check(ad))
+	assert.Equal(t, []string{"creative #1 has no closed captions"}, CaptionPolicy{Required: true}.Check(ad))
+	assert.Equal(t, []string{
+		"creative captioned has no closed captions in a required language and format",
+		"creative #1 has no closed captions",
+	}, CaptionPolicy{Required: true, Selector: CaptionSelector{Types: []string{CaptionTypeWebVTT}}}.Check(ad))
+	assert.Nil(t, CaptionPolicy{Required: true}.Check(&Ad{Wrapper: &Wrapper{}}))
+}
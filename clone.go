@@ -0,0 +1,68 @@
+package vast
+
+import "reflect"
+
+// Clone returns a deep copy of the VAST document. Pointer fields such as
+// InLine, Linear, Extensions or Offset.Duration are duplicated so that the
+// returned document never shares state with v.
+func (v *VAST) Clone() *VAST {
+	if v == nil {
+		return nil
+	}
+	c := new(VAST)
+	deepCopy(reflect.ValueOf(c).Elem(), reflect.ValueOf(v).Elem())
+	return c
+}
+
+// deepCopy recursively copies src into dst. Both values must be of the same
+// type and dst must be settable. Unexported struct fields are copied shallowly.
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		deepCopy(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		e := src.Elem()
+		c := reflect.New(e.Type()).Elem()
+		deepCopy(c, e)
+		dst.Set(c)
+	case reflect.Struct:
+		dst.Set(src)
+		t := src.Type()
+		for i := 0; i < src.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			deepCopy(dst.Field(i), src.Field(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for _, k := range src.MapKeys() {
+			e := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(e, src.MapIndex(k))
+			m.SetMapIndex(k, e)
+		}
+		dst.Set(m)
+	default:
+		dst.Set(src)
+	}
+}
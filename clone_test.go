@@ -0,0 +1,32 @@
+package vast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast4_universal_ad_id.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := v.Clone()
+	assert.Equal(t, v, c)
+
+	// mutating the clone must not affect the original document
+	c.Ads[0].InLine.AdSystem.Name = "changed"
+	c.Ads[0].InLine.Creatives[0].Linear.Duration = 0
+	*c.Ads[0].InLine.Creatives[0].Linear.TrackingEvents[0].Offset.Duration = 42
+	(*c.Ads[0].InLine.Extensions)[0].Type = "changed"
+	assert.NotEqual(t, "changed", v.Ads[0].InLine.AdSystem.Name)
+	assert.NotEqual(t, Duration(0), v.Ads[0].InLine.Creatives[0].Linear.Duration)
+	assert.Equal(t, Duration(8*time.Hour+34*time.Minute+time.Second), *v.Ads[0].InLine.Creatives[0].Linear.TrackingEvents[0].Offset.Duration)
+	assert.NotEqual(t, "changed", (*v.Ads[0].InLine.Extensions)[0].Type)
+}
+
+func TestCloneNil(t *testing.T) {
+	var v *VAST
+	assert.Nil(t, v.Clone())
+}
@@ -0,0 +1,200 @@
+// Package dash turns resolved VAST ad pods into MPEG-DASH periods for server
+// side ad insertion.
+//
+// Each ad of the pod becomes a Period starting at the break time, referencing
+// the DASH media file of the ad through its BaseURL, and carrying the ad
+// tracking URLs in EventStreams, one per tracking event, at the time the
+// event must be fired.
+package dash
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/vast"
+)
+
+// MediaType is the MIME type of DASH media files.
+const MediaType = "application/dash+xml"
+
+// DefaultScheme is the schemeIdUri of the tracking event streams.
+const DefaultScheme = "urn:vast:tracking"
+
+// AssetIDScheme is the schemeIdUri of the AssetIdentifier of ad periods.
+const AssetIDScheme = "urn:org:dashif:asset-id:2013"
+
+// timescale of the event streams, in ticks per second.
+const timescale = 1000
+
+// ErrNoAsset is returned when no ad of the pod has a DASH media file.
+var ErrNoAsset = errors.New("dash: no ad with a DASH streaming media file")
+
+// Options defines how periods are rendered.
+type Options struct {
+	// IDPrefix is prepended to the index of the ad in the pod to form the
+	// period id.
+	IDPrefix string
+	// Scheme is the schemeIdUri of the tracking event streams, DefaultScheme
+	// if empty.
+	Scheme string
+}
+
+// Duration is a duration encoded as an xs:duration (e.g. PT15.5S).
+type Duration time.Duration
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte("PT" + strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64) + "S"), nil
+}
+
+// Period is an MPD Period element.
+type Period struct {
+	XMLName  xml.Name `xml:"Period"`
+	ID       string   `xml:"id,attr"`
+	Start    Duration `xml:"start,attr"`
+	Duration Duration `xml:"duration,attr"`
+	// BaseURL references the DASH manifest of the ad. The stitcher is
+	// expected to insert its adaptation sets in the period.
+	BaseURL         string        `xml:"BaseURL"`
+	AssetIdentifier *Descriptor   `xml:"AssetIdentifier,omitempty"`
+	EventStreams    []EventStream `xml:"EventStream"`
+}
+
+// Descriptor is a DASH descriptor element.
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+}
+
+// EventStream is an MPD EventStream element.
+type EventStream struct {
+	SchemeIDURI string  `xml:"schemeIdUri,attr"`
+	Value       string  `xml:"value,attr,omitempty"`
+	Timescale   int     `xml:"timescale,attr"`
+	Events      []Event `xml:"Event"`
+}
+
+// Event is an MPD Event element, the tracking URL being its message data.
+type Event struct {
+	// PresentationTime is relative to the start of the period, in timescale
+	// ticks.
+	PresentationTime int64  `xml:"presentationTime,attr"`
+	ID               int    `xml:"id,attr"`
+	Data             string `xml:",chardata"`
+}
+
+// quartiles maps the linear tracking events fired at a fixed ratio of the ad
+// duration.
+var quartiles = []struct {
+	event string
+	ratio float64
+}{
+	{"creativeView", 0},
+	{"start", 0},
+	{"firstQuartile", 0.25},
+	{"midpoint", 0.5},
+	{"thirdQuartile", 0.75},
+	{"complete", 1},
+}
+
+// Periods returns the periods of the ads of v, inserted at the position at of
+// the content timeline. The ads of the pod are used in sequence order; if v
+// has no pod, its first stand-alone ad is used. Ads with no DASH streaming
+// media file are ignored. ErrNoAsset is returned for a nil document.
+func Periods(v *vast.VAST, at time.Duration, opts Options) ([]Period, error) {
+	if v == nil {
+		return nil, ErrNoAsset
+	}
+	if opts.Scheme == "" {
+		opts.Scheme = DefaultScheme
+	}
+	ads := v.Pod()
+	if len(ads) == 0 {
+		if buffet := v.Buffet(); len(buffet) > 0 {
+			ads = buffet[:1]
+		}
+	}
+	sel := vast.MediaSelector{Types: []string{MediaType}, Delivery: "streaming"}
+	var periods []Period
+	for i := range ads {
+		p, ok := newPeriod(&ads[i], sel, opts)
+		if !ok {
+			continue
+		}
+		p.ID = opts.IDPrefix + strconv.Itoa(len(periods))
+		p.Start = Duration(at)
+		at += time.Duration(p.Duration)
+		periods = append(periods, p)
+	}
+	if len(periods) == 0 {
+		return nil, ErrNoAsset
+	}
+	return periods, nil
+}
+
+func newPeriod(ad *vast.Ad, sel vast.MediaSelector, opts Options) (Period, bool) {
+	if ad.InLine == nil {
+		return Period{}, false
+	}
+	for _, c := range ad.InLine.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		m, ok := sel.Select(c.Linear.MediaFiles)
+		if !ok {
+			continue
+		}
+		dur := time.Duration(c.Linear.Duration)
+		p := Period{
+			Duration: Duration(dur),
+			BaseURL:  strings.TrimSpace(m.URI),
+		}
+		if ad.ID != "" {
+			p.AssetIdentifier = &Descriptor{SchemeIDURI: AssetIDScheme, Value: ad.ID}
+		}
+		id := 0
+		stream := func(event string) *EventStream {
+			for i := range p.EventStreams {
+				if p.EventStreams[i].Value == event {
+					return &p.EventStreams[i]
+				}
+			}
+			p.EventStreams = append(p.EventStreams, EventStream{SchemeIDURI: opts.Scheme, Value: event, Timescale: timescale})
+			return &p.EventStreams[len(p.EventStreams)-1]
+		}
+		add := func(event string, t time.Duration, u string) {
+			id++
+			es := stream(event)
+			es.Events = append(es.Events, Event{PresentationTime: int64(t / (time.Second / timescale)), ID: id, Data: u})
+		}
+		for _, u := range ad.ImpressionURLs() {
+			add("impression", 0, u)
+		}
+		for _, q := range quartiles {
+			for _, t := range c.Linear.TrackingEvents {
+				if t.Event == q.event {
+					add(q.event, time.Duration(float64(dur)*q.ratio), strings.TrimSpace(t.URI))
+				}
+			}
+		}
+		// progress events are fired at their own offset
+		for _, t := range c.Linear.TrackingEvents {
+			if t.Event == "progress" && t.Offset != nil {
+				add("progress", offset(*t.Offset, dur), strings.TrimSpace(t.URI))
+			}
+		}
+		return p, true
+	}
+	return Period{}, false
+}
+
+// offset returns the time at which an offset occurs in an ad of duration dur.
+func offset(o vast.Offset, dur time.Duration) time.Duration {
+	if o.Duration != nil {
+		return time.Duration(*o.Duration)
+	}
+	return time.Duration(float64(dur) * float64(o.Percent))
+}
@@ -0,0 +1,72 @@
+package dash
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/rs/vast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriods(t *testing.T) {
+	half := vast.Offset{Percent: 0.5}
+	ten := vast.Duration(10 * time.Second)
+	v := &vast.VAST{Version: "3.0", Ads: []vast.Ad{
+		{ID: "ad2", Sequence: 2, InLine: &vast.InLine{Creatives: []vast.Creative{{Linear: &vast.Linear{
+			Duration:   vast.Duration(10 * time.Second),
+			MediaFiles: []vast.MediaFile{{Delivery: "streaming", Type: "application/dash+xml", URI: "https://cdn/ad2.mpd"}},
+		}}}}},
+		{ID: "ad1", Sequence: 1, InLine: &vast.InLine{
+			Impressions: []vast.Impression{{URI: "http://track/imp"}},
+			Creatives: []vast.Creative{{Linear: &vast.Linear{
+				Duration: vast.Duration(20 * time.Second),
+				TrackingEvents: []vast.Tracking{
+					{Event: "start", URI: "http://track/start"},
+					{Event: "firstQuartile", URI: "http://track/q1"},
+					{Event: "complete", URI: "http://track/complete"},
+					{Event: "progress", Offset: &vast.Offset{Duration: &ten}, URI: "http://track/10s"},
+					{Event: "progress", Offset: &half, URI: "http://track/50p"},
+				},
+				MediaFiles: []vast.MediaFile{
+					{Delivery: "progressive", Type: "video/mp4", URI: "https://cdn/ad1.mp4"},
+					{Delivery: "streaming", Type: "application/dash+xml", URI: "https://cdn/ad1.mpd"},
+				},
+			}}},
+		}},
+		{ID: "ad3", Sequence: 3, InLine: &vast.InLine{}},
+	}}
+
+	periods, err := Periods(v, 30*time.Second, Options{IDPrefix: "break1-"})
+	if !assert.NoError(t, err) || !assert.Len(t, periods, 2) {
+		return
+	}
+	b, err := xml.Marshal(periods[0])
+	assert.NoError(t, err)
+	assert.Equal(t, `<Period id="break1-0" start="PT30S" duration="PT20S">`+
+		`<BaseURL>https://cdn/ad1.mpd</BaseURL>`+
+		`<AssetIdentifier schemeIdUri="urn:org:dashif:asset-id:2013" value="ad1"></AssetIdentifier>`+
+		`<EventStream schemeIdUri="urn:vast:tracking" value="impression" timescale="1000"><Event presentationTime="0" id="1">http://track/imp</Event></EventStream>`+
+		`<EventStream schemeIdUri="urn:vast:tracking" value="start" timescale="1000"><Event presentationTime="0" id="2">http://track/start</Event></EventStream>`+
+		`<EventStream schemeIdUri="urn:vast:tracking" value="firstQuartile" timescale="1000"><Event presentationTime="5000" id="3">http://track/q1</Event></EventStream>`+
+		`<EventStream schemeIdUri="urn:vast:tracking" value="complete" timescale="1000"><Event presentationTime="20000" id="4">http://track/complete</Event></EventStream>`+
+		`<EventStream schemeIdUri="urn:vast:tracking" value="progress" timescale="1000"><Event presentationTime="10000" id="5">http://track/10s</Event><Event presentationTime="10000" id="6">http://track/50p</Event></EventStream>`+
+		`</Period>`, string(b))
+
+	b, err = xml.Marshal(periods[1])
+	assert.NoError(t, err)
+	assert.Equal(t, `<Period id="break1-1" start="PT50S" duration="PT10S"><BaseURL>https://cdn/ad2.mpd</BaseURL><AssetIdentifier schemeIdUri="urn:org:dashif:asset-id:2013" value="ad2"></AssetIdentifier></Period>`, string(b))
+}
+
+func TestPeriodsNoAsset(t *testing.T) {
+	_, err := Periods(&vast.VAST{Ads: []vast.Ad{{InLine: &vast.InLine{}}}}, 0, Options{})
+	assert.Equal(t, ErrNoAsset, err)
+	_, err = Periods(nil, 0, Options{})
+	assert.Equal(t, ErrNoAsset, err)
+}
+
+func TestDurationMarshal(t *testing.T) {
+	b, err := Duration(1500 * time.Millisecond).MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "PT1.5S", string(b))
+}
@@ -0,0 +1,143 @@
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Default limits applied by Decode.
+const (
+	DefaultMaxDocumentSize = 1 << 20
+	DefaultMaxNestingDepth = 64
+	DefaultMaxAttributes   = 64
+	DefaultMaxElementSize  = 256 << 10
+)
+
+// ErrDecodeLimit is wrapped by the errors returned by Decode when a document
+// exceeds one of its limits.
+var ErrDecodeLimit = errors.New("vast: decode limit exceeded")
+
+// DecodeOptions defines the limits enforced by Decode. Zero values select the
+// default limits.
+type DecodeOptions struct {
+	// MaxSize is the size in bytes of the largest document accepted.
+	MaxSize int64
+	// MaxDepth is the maximum nesting depth of elements.
+	MaxDepth int
+	// MaxAttributes is the maximum number of attributes of an element.
+	MaxAttributes int
+	// MaxElementSize is the maximum size in bytes of the text content of an
+	// element, CDATA sections included.
+	MaxElementSize int
+	// Observer, if not nil, is notified of the outcome of each document
+	// decoded, limit hits included.
+	Observer Observer
+}
+
+// observe notifies the observer of opts, if any, of the decoding of a
+// document of n bytes started at start.
+func (opts DecodeOptions) observe(start time.Time, n int, err error) {
+	if opts.Observer == nil {
+		return
+	}
+	opts.Observer.OnDecode(DecodeEvent{
+		Bytes:    n,
+		Duration: time.Since(start),
+		Limit:    errors.Is(err, ErrDecodeLimit),
+		Err:      err,
+	})
+}
+
+func (opts DecodeOptions) withDefaults() DecodeOptions {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxDocumentSize
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxNestingDepth
+	}
+	if opts.MaxAttributes <= 0 {
+		opts.MaxAttributes = DefaultMaxAttributes
+	}
+	if opts.MaxElementSize <= 0 {
+		opts.MaxElementSize = DefaultMaxElementSize
+	}
+	return opts
+}
+
+// Decode reads and decodes a VAST document from r, enforcing the limits of
+// opts. It is meant to decode documents from untrusted sources: on top of the
+// size limits, documents with a DTD are rejected, so no entity can be
+// declared nor expanded, and a panic raised while decoding is returned as an
+// error.
+func Decode(r io.Reader, opts DecodeOptions) (v *VAST, err error) {
+	start, n := time.Now(), 0
+	defer func() { opts.observe(start, n, err) }()
+	opts = opts.withDefaults()
+	b, err := ioutil.ReadAll(io.LimitReader(r, opts.MaxSize+1))
+	n = len(b)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > opts.MaxSize {
+		return nil, fmt.Errorf("%w: document larger than %d bytes", ErrDecodeLimit, opts.MaxSize)
+	}
+	if err := checkLimits(b, opts); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("vast: invalid document: %v", r)
+		}
+	}()
+	v = &VAST{}
+	if err := xml.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// checkLimits tokenizes the document b and checks it against the limits of
+// opts, before it gets unmarshaled.
+func checkLimits(b []byte, opts DecodeOptions) error {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	// text size of each open element
+	var sizes []int
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(sizes) == opts.MaxDepth {
+				return fmt.Errorf("%w: elements nested deeper than %d", ErrDecodeLimit, opts.MaxDepth)
+			}
+			if len(tok.Attr) > opts.MaxAttributes {
+				return fmt.Errorf("%w: element %s with more than %d attributes", ErrDecodeLimit, tok.Name.Local, opts.MaxAttributes)
+			}
+			sizes = append(sizes, 0)
+		case xml.EndElement:
+			if len(sizes) > 0 {
+				sizes = sizes[:len(sizes)-1]
+			}
+		case xml.CharData:
+			if len(sizes) == 0 {
+				break
+			}
+			sizes[len(sizes)-1] += len(tok)
+			if sizes[len(sizes)-1] > opts.MaxElementSize {
+				return fmt.Errorf("%w: element text larger than %d bytes", ErrDecodeLimit, opts.MaxElementSize)
+			}
+		case xml.Directive:
+			return errors.New("vast: DTD not allowed")
+		}
+	}
+}
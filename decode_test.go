@@ -0,0 +1,66 @@
+package vast
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeFixtures(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.xml")
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		var want VAST
+		xml.Unmarshal(b, &want)
+		v, err := Decode(strings.NewReader(string(b)), DecodeOptions{})
+		if assert.NoError(t, err, f) {
+			assert.Equal(t, &want, v, f)
+		}
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	deep := strings.Repeat("<Extension>", 20) + strings.Repeat("</Extension>", 20)
+	for _, tt := range []struct {
+		name string
+		doc  string
+		opts DecodeOptions
+	}{
+		{"size", inlineDoc, DecodeOptions{MaxSize: 10}},
+		{"depth", `<VAST><Ad><InLine><Extensions>` + deep + `</Extensions></InLine></Ad></VAST>`, DecodeOptions{MaxDepth: 16}},
+		{"attributes", `<VAST a="1" b="2" c="3" version="3.0"/>`, DecodeOptions{MaxAttributes: 3}},
+		{"element", `<VAST><Ad><InLine><AdTitle>abc<![CDATA[def]]></AdTitle></InLine></Ad></VAST>`, DecodeOptions{MaxElementSize: 5}},
+	} {
+		_, err := Decode(strings.NewReader(tt.doc), tt.opts)
+		assert.True(t, errors.Is(err, ErrDecodeLimit), "%s: %v", tt.name, err)
+	}
+
+	v, err := Decode(strings.NewReader(inlineDoc), DecodeOptions{MaxSize: int64(len(inlineDoc)), MaxDepth: 4, MaxAttributes: 1, MaxElementSize: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "inline", v.Ads[0].ID)
+	}
+}
+
+func TestDecodeEntities(t *testing.T) {
+	laughs := `<?xml version="1.0"?>
+<!DOCTYPE VAST [
+ <!ENTITY lol "lol">
+ <!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+ <!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">
+]>
+<VAST version="3.0"><Ad><InLine><AdTitle>&lol2;</AdTitle></InLine></Ad></VAST>`
+	_, err := Decode(strings.NewReader(laughs), DecodeOptions{})
+	assert.EqualError(t, err, "vast: DTD not allowed")
+
+	// undeclared entities are not expanded either
+	_, err = Decode(strings.NewReader(`<VAST><Ad><InLine><AdTitle>&lol;</AdTitle></InLine></Ad></VAST>`), DecodeOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,684 @@
+package vast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DecodeBytes decodes the VAST document b with the limits of opts, like
+// Decode, using a hand-written decoder instead of the reflection based
+// encoding/xml package. The document decoded is the same as the one returned
+// by xml.Unmarshal.
+//
+// DecodeBytes is meant for hot paths: the decoding state is pooled and the
+// strings of the returned document share the memory of a single copy of b
+// whenever possible. That copy is retained as long as any of those strings
+// is referenced.
+func DecodeBytes(b []byte, opts DecodeOptions) (v *VAST, err error) {
+	start := time.Now()
+	defer func() { opts.observe(start, len(b), err) }()
+	opts = opts.withDefaults()
+	if int64(len(b)) > opts.MaxSize {
+		return nil, fmt.Errorf("%w: document larger than %d bytes", ErrDecodeLimit, opts.MaxSize)
+	}
+	d := decoderPool.Get().(*decoder)
+	d.s, d.opts = string(b), opts
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("vast: invalid document: %v", r)
+		}
+		d.reset()
+		decoderPool.Put(d)
+	}()
+	v = &VAST{}
+	if err := d.decodeDocument(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+var decoderPool = sync.Pool{
+	New: func() interface{} {
+		return &decoder{}
+	},
+}
+
+// decoder is a pull parser of XML documents, tailored to decode VAST
+// documents with the semantics of encoding/xml. It only allocates to unescape
+// text.
+type decoder struct {
+	s    string
+	pos  int
+	opts DecodeOptions
+	// stack of the open elements
+	stack []frame
+	// the last start tag read was an empty element tag, its end is pending
+	pendingEnd bool
+	// attributes of the last start tag read
+	attrs []attr
+	// in scope namespace declarations
+	ns []attr
+	// offset of the last end tag read
+	endPos int
+
+	// the character data of the element at depth tdepth is accumulated, in
+	// tstr when made of a single raw segment (tmode 1), or in buf (tmode 2)
+	tdepth int
+	tmode  int
+	tstr   string
+	buf    []byte
+	// scratch buffer for TextUnmarshaler values
+	scratch []byte
+}
+
+// frame is an open element.
+type frame struct {
+	name string
+	// size of the namespace declarations before the element
+	ns int
+	// size of the character data of the element
+	size int
+}
+
+// attr is an attribute, or a namespace declaration with the prefix as name.
+type attr struct {
+	name, value string
+}
+
+// startTag is a start element read by the decoder. Its attributes are only
+// valid until the next element is read.
+type startTag struct {
+	name  string
+	attrs []attr
+	// empty is true for an empty element tag (<a/>)
+	empty bool
+	// offset of the content of the element
+	pos int
+}
+
+func (d *decoder) reset() {
+	d.s, d.pos = "", 0
+	d.stack = d.stack[:0]
+	d.pendingEnd = false
+	d.attrs = d.attrs[:0]
+	d.ns = d.ns[:0]
+	d.tdepth, d.tmode, d.tstr = 0, 0, ""
+	d.buf = d.buf[:0]
+	d.scratch = d.scratch[:0]
+}
+
+func (d *decoder) syntaxError(msg string) error {
+	return &xml.SyntaxError{Msg: msg, Line: strings.Count(d.s[:d.pos], "\n") + 1}
+}
+
+// decodeDocument decodes the root element of the document into v. Like
+// xml.Unmarshal, the content following the root element is ignored.
+func (d *decoder) decodeDocument(v *VAST) error {
+	st, ok, err := d.next()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return d.syntaxError("unexpected end element")
+	}
+	return d.decodeVAST(v, st)
+}
+
+// next reads up to the next child element of the current element and returns
+// its start tag, or returns false once the end of the current element has
+// been read.
+func (d *decoder) next() (startTag, bool, error) {
+	if d.pendingEnd {
+		d.pendingEnd = false
+		d.endPos = d.pos
+		d.pop()
+		return startTag{}, false, nil
+	}
+	for {
+		if d.pos >= len(d.s) {
+			if len(d.stack) == 0 {
+				return startTag{}, false, d.syntaxError("no root element")
+			}
+			return startTag{}, false, d.syntaxError("unexpected EOF")
+		}
+		rest := d.s[d.pos:]
+		if rest[0] != '<' {
+			i := strings.IndexByte(rest, '<')
+			if i < 0 {
+				i = len(rest)
+			}
+			if err := d.charData(rest[:i], false); err != nil {
+				return startTag{}, false, err
+			}
+			d.pos += i
+			continue
+		}
+		switch {
+		case strings.HasPrefix(rest, "</"):
+			return startTag{}, false, d.endTag()
+		case strings.HasPrefix(rest, "<!--"):
+			i := strings.Index(rest[4:], "--")
+			if i < 0 || 4+i+2 >= len(rest) {
+				d.pos = len(d.s)
+				return startTag{}, false, d.syntaxError("unexpected EOF")
+			}
+			if rest[4+i+2] != '>' {
+				d.pos += 4 + i + 2
+				return startTag{}, false, d.syntaxError(`invalid sequence "--" not allowed in comments`)
+			}
+			d.pos += 4 + i + 3
+		case strings.HasPrefix(rest, "<![CDATA["):
+			i := strings.Index(rest[9:], "]]>")
+			if i < 0 {
+				d.pos = len(d.s)
+				return startTag{}, false, d.syntaxError("unexpected EOF in CDATA section")
+			}
+			if err := d.charData(rest[9:9+i], true); err != nil {
+				return startTag{}, false, err
+			}
+			d.pos += 9 + i + 3
+		case strings.HasPrefix(rest, "<!"):
+			return startTag{}, false, errDTD
+		case strings.HasPrefix(rest, "<?"):
+			if err := d.procInst(); err != nil {
+				return startTag{}, false, err
+			}
+		default:
+			st, err := d.startTag()
+			return st, err == nil, err
+		}
+	}
+}
+
+var errDTD = fmt.Errorf("vast: DTD not allowed")
+
+// startTag reads a start tag and opens its element.
+func (d *decoder) startTag() (startTag, error) {
+	d.pos++
+	name := d.name()
+	if name == "" {
+		return startTag{}, d.syntaxError("expected element name after <")
+	}
+	attrs := d.attrs[:0]
+	empty := false
+	for {
+		d.space()
+		if d.pos >= len(d.s) {
+			return startTag{}, d.syntaxError("unexpected EOF")
+		}
+		c := d.s[d.pos]
+		if c == '/' {
+			if d.pos+1 >= len(d.s) {
+				d.pos++
+				return startTag{}, d.syntaxError("unexpected EOF")
+			}
+			if d.s[d.pos+1] != '>' {
+				return startTag{}, d.syntaxError("expected /> in element")
+			}
+			d.pos += 2
+			empty = true
+			break
+		}
+		if c == '>' {
+			d.pos++
+			break
+		}
+		aname := d.name()
+		if aname == "" {
+			return startTag{}, d.syntaxError("expected attribute name in element")
+		}
+		d.space()
+		if d.pos >= len(d.s) {
+			return startTag{}, d.syntaxError("unexpected EOF")
+		}
+		if d.s[d.pos] != '=' {
+			return startTag{}, d.syntaxError("attribute name without = in element")
+		}
+		d.pos++
+		d.space()
+		if d.pos >= len(d.s) {
+			return startTag{}, d.syntaxError("unexpected EOF")
+		}
+		q := d.s[d.pos]
+		if q != '"' && q != '\'' {
+			return startTag{}, d.syntaxError("unquoted or missing attribute value in element")
+		}
+		d.pos++
+		i := strings.IndexByte(d.s[d.pos:], q)
+		if i < 0 {
+			d.pos = len(d.s)
+			return startTag{}, d.syntaxError("unexpected EOF")
+		}
+		value, err := d.attrValue(d.s[d.pos : d.pos+i])
+		if err != nil {
+			return startTag{}, err
+		}
+		d.pos += i + 1
+		attrs = append(attrs, attr{aname, value})
+	}
+	d.attrs = attrs
+	if len(attrs) > d.opts.MaxAttributes {
+		return startTag{}, fmt.Errorf("%w: element %s with more than %d attributes", ErrDecodeLimit, local(name), d.opts.MaxAttributes)
+	}
+	if len(d.stack) == d.opts.MaxDepth {
+		return startTag{}, fmt.Errorf("%w: elements nested deeper than %d", ErrDecodeLimit, d.opts.MaxDepth)
+	}
+	d.stack = append(d.stack, frame{name: name, ns: len(d.ns)})
+	for _, a := range attrs {
+		if a.name == "xmlns" {
+			d.ns = append(d.ns, attr{"", a.value})
+		} else if strings.HasPrefix(a.name, "xmlns:") {
+			d.ns = append(d.ns, attr{a.name[len("xmlns:"):], a.value})
+		}
+	}
+	d.pendingEnd = empty
+	return startTag{name: name, attrs: attrs, empty: empty, pos: d.pos}, nil
+}
+
+// endTag reads an end tag and closes the current element.
+func (d *decoder) endTag() error {
+	start := d.pos
+	d.pos += 2
+	name := d.name()
+	if name == "" {
+		return d.syntaxError("expected element name after </")
+	}
+	d.space()
+	if d.pos >= len(d.s) {
+		return d.syntaxError("unexpected EOF")
+	}
+	if d.s[d.pos] != '>' {
+		return d.syntaxError("invalid characters between </" + name + " and >")
+	}
+	d.pos++
+	if len(d.stack) == 0 {
+		return d.syntaxError("unexpected end element </" + name + ">")
+	}
+	if open := d.stack[len(d.stack)-1].name; open != name {
+		return d.syntaxError("element <" + open + "> closed by </" + name + ">")
+	}
+	d.endPos = start
+	d.pop()
+	return nil
+}
+
+func (d *decoder) pop() {
+	f := d.stack[len(d.stack)-1]
+	d.ns = d.ns[:f.ns]
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+// procInst skips a processing instruction, checking the XML declaration as
+// encoding/xml does.
+func (d *decoder) procInst() error {
+	d.pos += 2
+	target := d.name()
+	if target == "" {
+		return d.syntaxError("expected target name after <?")
+	}
+	i := strings.Index(d.s[d.pos:], "?>")
+	if i < 0 {
+		d.pos = len(d.s)
+		return d.syntaxError("unexpected EOF")
+	}
+	content := d.s[d.pos : d.pos+i]
+	d.pos += i + 2
+	if target != "xml" {
+		return nil
+	}
+	if ver := procInstParam("version", content); ver != "" && ver != "1.0" {
+		return fmt.Errorf("xml: unsupported version %q; only version 1.0 is supported", ver)
+	}
+	if enc := procInstParam("encoding", content); enc != "" && !strings.EqualFold(enc, "utf-8") {
+		return fmt.Errorf("xml: encoding %q declared but Decoder.CharsetReader is nil", enc)
+	}
+	return nil
+}
+
+// procInstParam returns the value of the param pseudo-attribute of the
+// processing instruction content s.
+func procInstParam(param, s string) string {
+	param += "="
+	for i := 0; i < len(s); {
+		k := strings.Index(s[i:], param)
+		if k < 0 || i+k+len(param) >= len(s) {
+			return ""
+		}
+		i += k + len(param)
+		if q := s[i]; q == '"' || q == '\'' {
+			if j := strings.IndexByte(s[i+1:], q); j >= 0 {
+				return s[i+1 : i+1+j]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// name reads an XML name.
+func (d *decoder) name() string {
+	start := d.pos
+	for d.pos < len(d.s) {
+		c := d.s[d.pos]
+		if c < utf8.RuneSelf && !isNameByte(c) {
+			break
+		}
+		d.pos++
+	}
+	name := d.s[start:d.pos]
+	if name == "" || strings.Count(name, ":") > 1 {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError && size == 1 || r < utf8.RuneSelf && !isNameStart(byte(r)) {
+		return ""
+	}
+	return name
+}
+
+func isNameByte(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '_' || c == ':' || c == '.' || c == '-'
+}
+
+func isNameStart(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || c == '_' || c == ':'
+}
+
+func (d *decoder) space() {
+	for d.pos < len(d.s) {
+		switch d.s[d.pos] {
+		case ' ', '\r', '\n', '\t':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+// local returns the local part of a qualified name.
+func local(name string) string {
+	if i := strings.IndexByte(name, ':'); i > 0 && i < len(name)-1 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// namespace returns the namespace of the qualified name of an element, the
+// prefix being translated with the declarations in scope.
+func (d *decoder) namespace(name string) string {
+	prefix := ""
+	if i := strings.IndexByte(name, ':'); i > 0 && i < len(name)-1 {
+		prefix = name[:i]
+	}
+	switch prefix {
+	case "xmlns":
+		return prefix
+	case "xml":
+		return "http://www.w3.org/XML/1998/namespace"
+	}
+	for i := len(d.ns) - 1; i >= 0; i-- {
+		if d.ns[i].name == prefix {
+			return d.ns[i].value
+		}
+	}
+	return prefix
+}
+
+// xmlAttr returns a as an xml.Attr, its prefix being translated like
+// encoding/xml does.
+func (d *decoder) xmlAttr(a attr) xml.Attr {
+	i := strings.IndexByte(a.name, ':')
+	if i <= 0 || i == len(a.name)-1 {
+		return xml.Attr{Name: xml.Name{Local: a.name}, Value: a.value}
+	}
+	return xml.Attr{Name: xml.Name{Space: d.namespace(a.name), Local: a.name[i+1:]}, Value: a.value}
+}
+
+// attrValue returns the unescaped value of an attribute.
+func (d *decoder) attrValue(raw string) (string, error) {
+	if strings.IndexByte(raw, '<') >= 0 {
+		return "", d.syntaxError("unescaped < inside quoted string")
+	}
+	if err := d.checkChars(raw); err != nil {
+		return "", err
+	}
+	if strings.IndexByte(raw, '&') < 0 && strings.IndexByte(raw, '\r') < 0 {
+		return raw, nil
+	}
+	var err error
+	d.scratch, err = d.unescape(d.scratch[:0], raw, false)
+	if err != nil {
+		return "", err
+	}
+	return string(d.scratch), nil
+}
+
+// charData handles a segment of character data of the current element.
+func (d *decoder) charData(seg string, cdata bool) error {
+	if !cdata && strings.Contains(seg, "]]>") {
+		return d.syntaxError("unescaped ]]> not in CDATA section")
+	}
+	if err := d.checkChars(seg); err != nil {
+		return err
+	}
+	n := len(d.stack)
+	if n > 0 {
+		f := &d.stack[n-1]
+		f.size += len(seg)
+		if f.size > d.opts.MaxElementSize {
+			return fmt.Errorf("%w: element text larger than %d bytes", ErrDecodeLimit, d.opts.MaxElementSize)
+		}
+	}
+	raw := strings.IndexByte(seg, '\r') < 0 && (cdata || strings.IndexByte(seg, '&') < 0)
+	if d.tdepth == 0 || n != d.tdepth {
+		if raw {
+			return nil
+		}
+		// check the character references
+		var err error
+		d.scratch, err = d.unescape(d.scratch[:0], seg, cdata)
+		return err
+	}
+	if raw && d.tmode == 0 {
+		d.tmode, d.tstr = 1, seg
+		return nil
+	}
+	switch d.tmode {
+	case 0:
+		d.buf = d.buf[:0]
+	case 1:
+		d.buf = append(d.buf[:0], d.tstr...)
+	}
+	d.tmode = 2
+	var err error
+	d.buf, err = d.unescape(d.buf, seg, cdata)
+	return err
+}
+
+// unescape appends the text s to dst, expanding the character references
+// unless in a CDATA section and normalizing the line breaks.
+func (d *decoder) unescape(dst []byte, s string, cdata bool) ([]byte, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\r':
+			dst = append(dst, '\n')
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case c == '&' && !cdata:
+			j := strings.IndexByte(s[i:], ';')
+			if j < 0 {
+				return nil, d.syntaxError("invalid character entity " + s[i:] + " (no semicolon)")
+			}
+			ent := s[i+1 : i+j]
+			r, ok := entityRune(ent)
+			if !ok {
+				return nil, d.syntaxError("invalid character entity &" + ent + ";")
+			}
+			// surrogates are replaced by U+FFFD
+			if !isXMLChar(r) && (r < 0xD800 || r > 0xDFFF) {
+				return nil, d.syntaxError(fmt.Sprintf("illegal character code %U", r))
+			}
+			dst = append(dst, string(r)...)
+			i += j
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst, nil
+}
+
+// entityRune returns the rune referenced by the entity or character
+// reference name.
+func entityRune(name string) (rune, bool) {
+	switch name {
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "amp":
+		return '&', true
+	case "apos":
+		return '\'', true
+	case "quot":
+		return '"', true
+	}
+	if len(name) < 2 || name[0] != '#' {
+		return 0, false
+	}
+	digits, base := name[1:], 10
+	if digits[0] == 'x' {
+		digits, base = digits[1:], 16
+	}
+	if digits == "" || digits[0] == '+' || digits[0] == '-' || strings.IndexByte(digits, '_') >= 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil || n > utf8.MaxRune {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// checkChars checks that s is valid UTF-8 made of characters allowed by XML.
+func (d *decoder) checkChars(s string) error {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+				return d.syntaxError(fmt.Sprintf("illegal character code %U", rune(c)))
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return d.syntaxError("invalid UTF-8")
+		}
+		if !isXMLChar(r) {
+			return d.syntaxError(fmt.Sprintf("illegal character code %U", r))
+		}
+		i += size
+	}
+	return nil
+}
+
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD || r >= 0x10000 && r <= 0x10FFFF
+}
+
+// skip skips the content of the current element.
+func (d *decoder) skip() error {
+	for {
+		_, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+}
+
+// text returns the character data of the current element, skipping its
+// child elements.
+func (d *decoder) text() (string, error) {
+	d.tdepth, d.tmode = len(d.stack), 0
+	err := d.skip()
+	d.tdepth = 0
+	if err != nil {
+		return "", err
+	}
+	switch d.tmode {
+	case 1:
+		return d.tstr, nil
+	case 2:
+		return string(d.buf), nil
+	}
+	return "", nil
+}
+
+// cdata decodes the character data of the current element into s.
+func (d *decoder) cdata(s *string) (err error) {
+	*s, err = d.text()
+	return err
+}
+
+// int decodes the value of the current element into n.
+func (d *decoder) int(n *int) error {
+	s, err := d.text()
+	if err != nil {
+		return err
+	}
+	return parseInt(n, s)
+}
+
+// unmarshalText decodes the value s into u.
+func (d *decoder) unmarshalText(u interface{ UnmarshalText([]byte) error }, s string) error {
+	d.scratch = append(d.scratch[:0], s...)
+	return u.UnmarshalText(d.scratch)
+}
+
+// parseInt parses s into n the way encoding/xml does.
+func parseInt(n *int, s string) error {
+	if s == "" {
+		*n = 0
+		return nil
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 0)
+	if err != nil {
+		return err
+	}
+	*n = int(i)
+	return nil
+}
+
+// parseBool parses s into b the way encoding/xml does.
+func parseBool(b *bool, s string) error {
+	if s == "" {
+		*b = false
+		return nil
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// parseBoolPtr parses s into a new bool assigned to *b.
+func parseBoolPtr(b **bool, s string) error {
+	if *b == nil {
+		*b = new(bool)
+	}
+	return parseBool(*b, s)
+}
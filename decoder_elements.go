@@ -0,0 +1,957 @@
+package vast
+
+import "encoding/xml"
+
+// The functions of this file decode the elements of a VAST document, with
+// the same semantics as the encoding/xml tags of the types of vast.go. Each
+// function is called once the start tag of its element has been read and
+// returns once its end tag has been read.
+
+// children calls f with the local name and the start tag of each child
+// element of the current element. f must decode or skip the child.
+func (d *decoder) children(f func(name string, st startTag) error) error {
+	for {
+		st, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := f(local(st.name), st); err != nil {
+			return err
+		}
+	}
+}
+
+// cdataString decodes the character data of the current element into a new
+// CDATAString appended to s.
+func (d *decoder) cdataString(s *[]CDATAString) error {
+	*s = append(*s, CDATAString{})
+	return d.cdata(&(*s)[len(*s)-1].CDATA)
+}
+
+// trackingEvents decodes the Tracking children of the current element into
+// events.
+func (d *decoder) trackingEvents(events *[]Tracking) error {
+	return d.children(func(name string, st startTag) error {
+		if name != "Tracking" {
+			return d.skip()
+		}
+		*events = append(*events, Tracking{})
+		return d.decodeTracking(&(*events)[len(*events)-1], st)
+	})
+}
+
+func (d *decoder) decodeVAST(v *VAST, st startTag) error {
+	v.root = xml.Name{Space: d.namespace(st.name), Local: local(st.name)}
+	for _, a := range st.attrs {
+		if local(a.name) == "version" {
+			v.Version = a.value
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "Ad":
+			v.Ads = append(v.Ads, Ad{})
+			return d.decodeAd(&v.Ads[len(v.Ads)-1], st)
+		case "Error":
+			return d.cdataString(&v.Errors)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeAd(ad *Ad, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			ad.ID = a.value
+		case "sequence":
+			err = parseInt(&ad.Sequence, a.value)
+		case "adType":
+			ad.AdType = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "InLine":
+			if ad.InLine == nil {
+				ad.InLine = &InLine{}
+			}
+			return d.decodeInLine(ad.InLine, st)
+		case "Wrapper":
+			if ad.Wrapper == nil {
+				ad.Wrapper = &Wrapper{}
+			}
+			return d.decodeWrapper(ad.Wrapper, st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeInLine(in *InLine, st startTag) error {
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "AdSystem":
+			if in.AdSystem == nil {
+				in.AdSystem = &AdSystem{}
+			}
+			return d.decodeAdSystem(in.AdSystem, st)
+		case "AdTitle":
+			return d.cdata(&in.AdTitle.CDATA)
+		case "Impression":
+			in.Impressions = append(in.Impressions, Impression{})
+			return d.decodeImpression(&in.Impressions[len(in.Impressions)-1], st)
+		case "Creatives":
+			return d.children(func(name string, st startTag) error {
+				if name != "Creative" {
+					return d.skip()
+				}
+				in.Creatives = append(in.Creatives, Creative{})
+				return d.decodeCreative(&in.Creatives[len(in.Creatives)-1], st)
+			})
+		case "Description":
+			return d.cdata(&in.Description.CDATA)
+		case "Advertiser":
+			return d.cdata(&in.Advertiser)
+		case "Survey":
+			return d.cdata(&in.Survey.CDATA)
+		case "Error":
+			return d.cdataString(&in.Errors)
+		case "Pricing":
+			if in.Pricing == nil {
+				in.Pricing = &Pricing{}
+			}
+			return d.decodePricing(in.Pricing, st)
+		case "Extensions":
+			return d.children(func(name string, st startTag) error {
+				if name != "Extension" {
+					return d.skip()
+				}
+				if in.Extensions == nil {
+					in.Extensions = &[]Extension{}
+				}
+				*in.Extensions = append(*in.Extensions, Extension{})
+				return d.decodeExtension(&(*in.Extensions)[len(*in.Extensions)-1], st)
+			})
+		case "AdVerifications":
+			return d.children(func(name string, st startTag) error {
+				if name != "Verification" {
+					return d.skip()
+				}
+				in.AdVerifications = append(in.AdVerifications, Verification{})
+				return d.decodeVerification(&in.AdVerifications[len(in.AdVerifications)-1], st)
+			})
+		case "Expires":
+			return d.int(&in.Expires)
+		case "Category":
+			in.Categories = append(in.Categories, Category{})
+			return d.decodeCategory(&in.Categories[len(in.Categories)-1], st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeCategory(c *Category, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "authority" {
+			c.Authority = a.value
+		}
+	}
+	return d.cdata(&c.Code)
+}
+
+func (d *decoder) decodeImpression(imp *Impression, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "id" {
+			imp.ID = a.value
+		}
+	}
+	return d.cdata(&imp.URI)
+}
+
+func (d *decoder) decodePricing(p *Pricing, st startTag) error {
+	for _, a := range st.attrs {
+		switch local(a.name) {
+		case "model":
+			p.Model = a.value
+		case "currency":
+			p.Currency = a.value
+		}
+	}
+	return d.cdata(&p.Value)
+}
+
+func (d *decoder) decodeWrapper(w *Wrapper, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "fallbackOnNoAd":
+			err = parseBoolPtr(&w.FallbackOnNoAd, a.value)
+		case "allowMultipleAds":
+			err = parseBoolPtr(&w.AllowMultipleAds, a.value)
+		case "followAdditionalWrappers":
+			err = parseBoolPtr(&w.FollowAdditionalWrappers, a.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// the DAAST ad tag URI is merged once decoded, as UnmarshalXML does
+	var daast *CDATAString
+	err := d.children(func(name string, st startTag) error {
+		switch name {
+		case "AdSystem":
+			if w.AdSystem == nil {
+				w.AdSystem = &AdSystem{}
+			}
+			return d.decodeAdSystem(w.AdSystem, st)
+		case "VASTAdTagURI":
+			return d.cdata(&w.VASTAdTagURI.CDATA)
+		case "DAASTAdTagURI":
+			if daast == nil {
+				daast = &CDATAString{}
+			}
+			return d.cdata(&daast.CDATA)
+		case "Impression":
+			w.Impressions = append(w.Impressions, Impression{})
+			return d.decodeImpression(&w.Impressions[len(w.Impressions)-1], st)
+		case "Error":
+			return d.cdataString(&w.Errors)
+		case "Pricing":
+			if w.Pricing == nil {
+				w.Pricing = &Pricing{}
+			}
+			return d.decodePricing(w.Pricing, st)
+		case "Creatives":
+			return d.children(func(name string, st startTag) error {
+				if name != "Creative" {
+					return d.skip()
+				}
+				w.Creatives = append(w.Creatives, CreativeWrapper{})
+				return d.decodeCreativeWrapper(&w.Creatives[len(w.Creatives)-1], st)
+			})
+		case "Extensions":
+			return d.children(func(name string, st startTag) error {
+				if name != "Extension" {
+					return d.skip()
+				}
+				w.Extensions = append(w.Extensions, Extension{})
+				return d.decodeExtension(&w.Extensions[len(w.Extensions)-1], st)
+			})
+		case "AdVerifications":
+			return d.children(func(name string, st startTag) error {
+				if name != "Verification" {
+					return d.skip()
+				}
+				w.AdVerifications = append(w.AdVerifications, Verification{})
+				return d.decodeVerification(&w.AdVerifications[len(w.AdVerifications)-1], st)
+			})
+		}
+		return d.skip()
+	})
+	if err != nil {
+		return err
+	}
+	mergeAdTagURI(&w.VASTAdTagURI, daast)
+	return nil
+}
+
+func (d *decoder) decodeAdSystem(s *AdSystem, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "version" {
+			s.Version = a.value
+		}
+	}
+	return d.cdata(&s.Name)
+}
+
+func (d *decoder) decodeCreative(c *Creative, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			c.ID = a.value
+		case "sequence":
+			err = parseInt(&c.Sequence, a.value)
+		case "AdID":
+			c.AdID = a.value
+		case "apiFramework":
+			c.APIFramework = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &Linear{}
+			}
+			return d.decodeLinear(c.Linear, st)
+		case "CompanionAds":
+			if c.CompanionAds == nil {
+				c.CompanionAds = &CompanionAds{}
+			}
+			return d.decodeCompanionAds(c.CompanionAds, st)
+		case "NonLinearAds":
+			if c.NonLinearAds == nil {
+				c.NonLinearAds = &NonLinearAds{}
+			}
+			return d.decodeNonLinearAds(c.NonLinearAds, st)
+		case "UniversalAdId":
+			if c.UniversalAdID == nil {
+				c.UniversalAdID = &UniversalAdID{}
+			}
+			return d.decodeUniversalAdID(c.UniversalAdID, st)
+		case "CreativeExtensions":
+			return d.children(func(name string, st startTag) error {
+				if name != "CreativeExtension" {
+					return d.skip()
+				}
+				if c.CreativeExtensions == nil {
+					c.CreativeExtensions = &[]Extension{}
+				}
+				*c.CreativeExtensions = append(*c.CreativeExtensions, Extension{})
+				return d.decodeExtension(&(*c.CreativeExtensions)[len(*c.CreativeExtensions)-1], st)
+			})
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeCompanionAds(c *CompanionAds, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "required" {
+			c.Required = a.value
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		if name != "Companion" {
+			return d.skip()
+		}
+		c.Companions = append(c.Companions, Companion{})
+		return d.decodeCompanion(&c.Companions[len(c.Companions)-1], st)
+	})
+}
+
+func (d *decoder) decodeNonLinearAds(n *NonLinearAds, st startTag) error {
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "TrackingEvents":
+			return d.trackingEvents(&n.TrackingEvents)
+		case "NonLinear":
+			n.NonLinears = append(n.NonLinears, NonLinear{})
+			return d.decodeNonLinear(&n.NonLinears[len(n.NonLinears)-1], st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeCreativeWrapper(c *CreativeWrapper, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			c.ID = a.value
+		case "sequence":
+			err = parseInt(&c.Sequence, a.value)
+		case "AdID":
+			c.AdID = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "Linear":
+			if c.Linear == nil {
+				c.Linear = &LinearWrapper{}
+			}
+			return d.decodeLinearWrapper(c.Linear, st)
+		case "CompanionAds":
+			if c.CompanionAds == nil {
+				c.CompanionAds = &CompanionAdsWrapper{}
+			}
+			return d.decodeCompanionAdsWrapper(c.CompanionAds, st)
+		case "NonLinearAds":
+			if c.NonLinearAds == nil {
+				c.NonLinearAds = &NonLinearAdsWrapper{}
+			}
+			return d.decodeNonLinearAdsWrapper(c.NonLinearAds, st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeCompanionAdsWrapper(c *CompanionAdsWrapper, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "required" {
+			c.Required = a.value
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		if name != "Companion" {
+			return d.skip()
+		}
+		c.Companions = append(c.Companions, CompanionWrapper{})
+		// Companion and CompanionWrapper only differ by their documentation
+		return d.decodeCompanion((*Companion)(&c.Companions[len(c.Companions)-1]), st)
+	})
+}
+
+func (d *decoder) decodeNonLinearAdsWrapper(n *NonLinearAdsWrapper, st startTag) error {
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "TrackingEvents":
+			return d.trackingEvents(&n.TrackingEvents)
+		case "NonLinear":
+			n.NonLinears = append(n.NonLinears, NonLinearWrapper{})
+			return d.decodeNonLinearWrapper(&n.NonLinears[len(n.NonLinears)-1], st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeLinear(l *Linear, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "skipoffset" {
+			if l.SkipOffset == nil {
+				l.SkipOffset = &Offset{}
+			}
+			if err := d.unmarshalText(l.SkipOffset, a.value); err != nil {
+				return err
+			}
+		}
+	}
+	// the DAAST audio interactions are merged once decoded, as UnmarshalXML
+	// does
+	var audio *VideoClicks
+	err := d.children(func(name string, st startTag) error {
+		switch name {
+		case "Duration":
+			s, err := d.text()
+			if err != nil {
+				return err
+			}
+			return d.unmarshalText(&l.Duration, s)
+		case "AdParameters":
+			if l.AdParameters == nil {
+				l.AdParameters = &AdParameters{}
+			}
+			return d.decodeAdParameters(l.AdParameters, st)
+		case "Icons":
+			if l.Icons == nil {
+				l.Icons = &Icons{}
+			}
+			return d.decodeIcons(l.Icons, st)
+		case "TrackingEvents":
+			return d.trackingEvents(&l.TrackingEvents)
+		case "VideoClicks":
+			if l.VideoClicks == nil {
+				l.VideoClicks = &VideoClicks{}
+			}
+			return d.decodeVideoClicks(l.VideoClicks, st)
+		case "AudioInteractions":
+			if audio == nil {
+				audio = &VideoClicks{}
+			}
+			return d.decodeVideoClicks(audio, st)
+		case "MediaFiles":
+			return d.children(func(name string, st startTag) error {
+				switch name {
+				case "MediaFile":
+					l.MediaFiles = append(l.MediaFiles, MediaFile{})
+					return d.decodeMediaFile(&l.MediaFiles[len(l.MediaFiles)-1], st)
+				case "InteractiveCreativeFile":
+					l.InteractiveCreativeFiles = append(l.InteractiveCreativeFiles, InteractiveCreativeFile{})
+					return d.decodeInteractiveCreativeFile(&l.InteractiveCreativeFiles[len(l.InteractiveCreativeFiles)-1], st)
+				case "ClosedCaptionFiles":
+					return d.children(func(name string, st startTag) error {
+						if name != "ClosedCaptionFile" {
+							return d.skip()
+						}
+						l.ClosedCaptionFiles = append(l.ClosedCaptionFiles, ClosedCaptionFile{})
+						return d.decodeClosedCaptionFile(&l.ClosedCaptionFiles[len(l.ClosedCaptionFiles)-1], st)
+					})
+				}
+				return d.skip()
+			})
+		}
+		return d.skip()
+	})
+	if err != nil {
+		return err
+	}
+	mergeClicks(&l.VideoClicks, audio)
+	return nil
+}
+
+func (d *decoder) decodeLinearWrapper(l *LinearWrapper, st startTag) error {
+	var audio *VideoClicks
+	err := d.children(func(name string, st startTag) error {
+		switch name {
+		case "Icons":
+			if l.Icons == nil {
+				l.Icons = &Icons{}
+			}
+			return d.decodeIcons(l.Icons, st)
+		case "TrackingEvents":
+			return d.trackingEvents(&l.TrackingEvents)
+		case "VideoClicks":
+			if l.VideoClicks == nil {
+				l.VideoClicks = &VideoClicks{}
+			}
+			return d.decodeVideoClicks(l.VideoClicks, st)
+		case "AudioInteractions":
+			if audio == nil {
+				audio = &VideoClicks{}
+			}
+			return d.decodeVideoClicks(audio, st)
+		}
+		return d.skip()
+	})
+	if err != nil {
+		return err
+	}
+	mergeClicks(&l.VideoClicks, audio)
+	return nil
+}
+
+func (d *decoder) decodeCompanion(c *Companion, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			c.ID = a.value
+		case "width":
+			err = parseInt(&c.Width, a.value)
+		case "height":
+			err = parseInt(&c.Height, a.value)
+		case "assetWidth":
+			err = parseInt(&c.AssetWidth, a.value)
+		case "assetHeight":
+			err = parseInt(&c.AssetHeight, a.value)
+		case "expandedWidth":
+			err = parseInt(&c.ExpandedWidth, a.value)
+		case "expandedHeight":
+			err = parseInt(&c.ExpandedHeight, a.value)
+		case "apiFramework":
+			c.APIFramework = a.value
+		case "adSlotId":
+			c.AdSlotID = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "CompanionClickThrough":
+			return d.cdata(&c.CompanionClickThrough.CDATA)
+		case "CompanionClickTracking":
+			return d.cdataString(&c.CompanionClickTracking)
+		case "AltText":
+			return d.cdata(&c.AltText)
+		case "TrackingEvents":
+			return d.trackingEvents(&c.TrackingEvents)
+		case "AdParameters":
+			if c.AdParameters == nil {
+				c.AdParameters = &AdParameters{}
+			}
+			return d.decodeAdParameters(c.AdParameters, st)
+		case "StaticResource":
+			if c.StaticResource == nil {
+				c.StaticResource = &StaticResource{}
+			}
+			return d.decodeStaticResource(c.StaticResource, st)
+		case "IFrameResource":
+			return d.cdata(&c.IFrameResource.CDATA)
+		case "HTMLResource":
+			if c.HTMLResource == nil {
+				c.HTMLResource = &HTMLResource{}
+			}
+			return d.decodeHTMLResource(c.HTMLResource, st)
+		}
+		return d.skip()
+	})
+}
+
+// nonLinearAttrs decodes the attributes shared by NonLinear and
+// NonLinearWrapper.
+func (d *decoder) nonLinearAttrs(n *NonLinear, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			n.ID = a.value
+		case "width":
+			err = parseInt(&n.Width, a.value)
+		case "height":
+			err = parseInt(&n.Height, a.value)
+		case "expandedWidth":
+			err = parseInt(&n.ExpandedWidth, a.value)
+		case "expandedHeight":
+			err = parseInt(&n.ExpandedHeight, a.value)
+		case "scalable":
+			err = parseBool(&n.Scalable, a.value)
+		case "maintainAspectRatio":
+			err = parseBool(&n.MaintainAspectRatio, a.value)
+		case "minSuggestedDuration":
+			if n.MinSuggestedDuration == nil {
+				n.MinSuggestedDuration = new(Duration)
+			}
+			err = d.unmarshalText(n.MinSuggestedDuration, a.value)
+		case "apiFramework":
+			n.APIFramework = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeNonLinear(n *NonLinear, st startTag) error {
+	if err := d.nonLinearAttrs(n, st); err != nil {
+		return err
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "NonLinearClickTracking":
+			return d.cdataString(&n.NonLinearClickTracking)
+		case "NonLinearClickThrough":
+			return d.cdata(&n.NonLinearClickThrough.CDATA)
+		case "AdParameters":
+			if n.AdParameters == nil {
+				n.AdParameters = &AdParameters{}
+			}
+			return d.decodeAdParameters(n.AdParameters, st)
+		case "StaticResource":
+			if n.StaticResource == nil {
+				n.StaticResource = &StaticResource{}
+			}
+			return d.decodeStaticResource(n.StaticResource, st)
+		case "IFrameResource":
+			return d.cdata(&n.IFrameResource.CDATA)
+		case "HTMLResource":
+			if n.HTMLResource == nil {
+				n.HTMLResource = &HTMLResource{}
+			}
+			return d.decodeHTMLResource(n.HTMLResource, st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeNonLinearWrapper(n *NonLinearWrapper, st startTag) error {
+	var attrs NonLinear
+	if err := d.nonLinearAttrs(&attrs, st); err != nil {
+		return err
+	}
+	n.ID, n.Width, n.Height = attrs.ID, attrs.Width, attrs.Height
+	n.ExpandedWidth, n.ExpandedHeight = attrs.ExpandedWidth, attrs.ExpandedHeight
+	n.Scalable, n.MaintainAspectRatio = attrs.Scalable, attrs.MaintainAspectRatio
+	n.MinSuggestedDuration, n.APIFramework = attrs.MinSuggestedDuration, attrs.APIFramework
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "TrackingEvents":
+			return d.trackingEvents(&n.TrackingEvents)
+		case "NonLinearClickTracking":
+			return d.cdataString(&n.NonLinearClickTracking)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeIcons(icons *Icons, st startTag) error {
+	icons.XMLName = xml.Name{Space: d.namespace(st.name), Local: local(st.name)}
+	return d.children(func(name string, st startTag) error {
+		if name != "Icon" {
+			return d.skip()
+		}
+		icons.Icon = append(icons.Icon, Icon{})
+		return d.decodeIcon(&icons.Icon[len(icons.Icon)-1], st)
+	})
+}
+
+func (d *decoder) decodeIcon(icon *Icon, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "program":
+			icon.Program = a.value
+		case "width":
+			err = parseInt(&icon.Width, a.value)
+		case "height":
+			err = parseInt(&icon.Height, a.value)
+		case "xPosition":
+			icon.XPosition = a.value
+		case "yPosition":
+			icon.YPosition = a.value
+		case "offset":
+			err = d.unmarshalText(&icon.Offset, a.value)
+		case "duration":
+			err = d.unmarshalText(&icon.Duration, a.value)
+		case "apiFramework":
+			icon.APIFramework = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "IconClicks":
+			return d.children(func(name string, st startTag) error {
+				switch name {
+				case "IconClickThrough":
+					return d.cdata(&icon.IconClickThrough.CDATA)
+				case "IconClickTracking":
+					return d.cdataString(&icon.IconClickTrackings)
+				}
+				return d.skip()
+			})
+		case "StaticResource":
+			if icon.StaticResource == nil {
+				icon.StaticResource = &StaticResource{}
+			}
+			return d.decodeStaticResource(icon.StaticResource, st)
+		case "IFrameResource":
+			return d.cdata(&icon.IFrameResource.CDATA)
+		case "HTMLResource":
+			if icon.HTMLResource == nil {
+				icon.HTMLResource = &HTMLResource{}
+			}
+			return d.decodeHTMLResource(icon.HTMLResource, st)
+		}
+		return d.skip()
+	})
+}
+
+func (d *decoder) decodeTracking(t *Tracking, st startTag) error {
+	for _, a := range st.attrs {
+		switch local(a.name) {
+		case "event":
+			t.Event = a.value
+		case "offset":
+			if t.Offset == nil {
+				t.Offset = &Offset{}
+			}
+			if err := d.unmarshalText(t.Offset, a.value); err != nil {
+				return err
+			}
+		}
+	}
+	return d.cdata(&t.URI)
+}
+
+func (d *decoder) decodeStaticResource(r *StaticResource, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "creativeType" {
+			r.CreativeType = a.value
+		}
+	}
+	return d.cdata(&r.URI)
+}
+
+func (d *decoder) decodeHTMLResource(r *HTMLResource, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "xmlEncoded" {
+			if err := parseBool(&r.XMLEncoded, a.value); err != nil {
+				return err
+			}
+		}
+	}
+	return d.cdata(&r.HTML)
+}
+
+func (d *decoder) decodeAdParameters(p *AdParameters, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "xmlEncoded" {
+			if err := parseBool(&p.XMLEncoded, a.value); err != nil {
+				return err
+			}
+		}
+	}
+	return d.cdata(&p.Parameters)
+}
+
+func (d *decoder) decodeVideoClicks(c *VideoClicks, st startTag) error {
+	return d.children(func(name string, st startTag) error {
+		var clicks *[]VideoClick
+		switch name {
+		case "ClickThrough":
+			clicks = &c.ClickThroughs
+		case "ClickTracking":
+			clicks = &c.ClickTrackings
+		case "CustomClick":
+			clicks = &c.CustomClicks
+		default:
+			return d.skip()
+		}
+		*clicks = append(*clicks, VideoClick{})
+		click := &(*clicks)[len(*clicks)-1]
+		for _, a := range st.attrs {
+			if local(a.name) == "id" {
+				click.ID = a.value
+			}
+		}
+		return d.cdata(&click.URI)
+	})
+}
+
+func (d *decoder) decodeMediaFile(m *MediaFile, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "id":
+			m.ID = a.value
+		case "delivery":
+			m.Delivery = a.value
+		case "type":
+			m.Type = a.value
+		case "codec":
+			m.Codec = a.value
+		case "bitrate":
+			err = parseInt(&m.Bitrate, a.value)
+		case "minBitrate":
+			err = parseInt(&m.MinBitrate, a.value)
+		case "maxBitrate":
+			err = parseInt(&m.MaxBitrate, a.value)
+		case "width":
+			err = parseInt(&m.Width, a.value)
+		case "height":
+			err = parseInt(&m.Height, a.value)
+		case "scalable":
+			err = parseBool(&m.Scalable, a.value)
+		case "maintainAspectRatio":
+			err = parseBool(&m.MaintainAspectRatio, a.value)
+		case "apiFramework":
+			m.APIFramework = a.value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.cdata(&m.URI)
+}
+
+func (d *decoder) decodeClosedCaptionFile(f *ClosedCaptionFile, st startTag) error {
+	for _, a := range st.attrs {
+		switch local(a.name) {
+		case "type":
+			f.Type = a.value
+		case "language":
+			f.Language = a.value
+		}
+	}
+	return d.cdata(&f.URI)
+}
+
+func (d *decoder) decodeInteractiveCreativeFile(f *InteractiveCreativeFile, st startTag) error {
+	for _, a := range st.attrs {
+		var err error
+		switch local(a.name) {
+		case "type":
+			f.Type = a.value
+		case "apiFramework":
+			f.APIFramework = a.value
+		case "variableDuration":
+			err = parseBool(&f.VariableDuration, a.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.cdata(&f.URI)
+}
+
+func (d *decoder) decodeUniversalAdID(id *UniversalAdID, st startTag) error {
+	for _, a := range st.attrs {
+		switch local(a.name) {
+		case "idRegistry":
+			id.IDRegistry = a.value
+		case "idValue":
+			id.IDValue = a.value
+		}
+	}
+	return d.cdata(&id.ID)
+}
+
+func (d *decoder) decodeVerification(v *Verification, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "vendor" {
+			v.Vendor = a.value
+		}
+	}
+	return d.children(func(name string, st startTag) error {
+		switch name {
+		case "JavaScriptResource":
+			v.JavaScriptResource = append(v.JavaScriptResource, JavaScriptResource{})
+			r := &v.JavaScriptResource[len(v.JavaScriptResource)-1]
+			for _, a := range st.attrs {
+				switch local(a.name) {
+				case "apiFramework":
+					r.APIFramework = a.value
+				case "browserOptional":
+					if err := parseBool(&r.BrowserOptional, a.value); err != nil {
+						return err
+					}
+				}
+			}
+			return d.cdata(&r.URI)
+		case "ExecutableResource":
+			v.ExecutableResource = append(v.ExecutableResource, ExecutableResource{})
+			r := &v.ExecutableResource[len(v.ExecutableResource)-1]
+			for _, a := range st.attrs {
+				switch local(a.name) {
+				case "apiFramework":
+					r.APIFramework = a.value
+				case "type":
+					r.Type = a.value
+				}
+			}
+			return d.cdata(&r.URI)
+		case "TrackingEvents":
+			return d.trackingEvents(&v.TrackingEvents)
+		case "VerificationParameters":
+			if v.VerificationParameters == nil {
+				v.VerificationParameters = &CDATAString{}
+			}
+			return d.cdata(&v.VerificationParameters.CDATA)
+		}
+		return d.skip()
+	})
+}
+
+// decodeExtension decodes an extension like Extension.UnmarshalXML does.
+func (d *decoder) decodeExtension(e *Extension, st startTag) error {
+	for _, a := range st.attrs {
+		if local(a.name) == "type" {
+			e.Type = a.value
+			continue
+		}
+		e.Attrs = append(e.Attrs, d.xmlAttr(a))
+	}
+	err := d.children(func(name string, st startTag) error {
+		if name != "CustomTracking" {
+			return d.skip()
+		}
+		return d.trackingEvents(&e.CustomTracking)
+	})
+	if err != nil {
+		return err
+	}
+	if !st.empty {
+		e.Data = []byte(d.s[st.pos:d.endPos])
+	}
+	if len(e.CustomTracking) > 0 {
+		e.Data = stripCustomTracking(e.Data)
+	}
+	return nil
+}
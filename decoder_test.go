@@ -0,0 +1,135 @@
+package vast
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadFixtures(tb testing.TB) map[string][]byte {
+	files, err := filepath.Glob("testdata/*.xml")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	docs := map[string][]byte{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		docs[f] = b
+	}
+	return docs
+}
+
+func TestDecodeBytesFixtures(t *testing.T) {
+	for f, b := range loadFixtures(t) {
+		var want VAST
+		if !assert.NoError(t, xml.Unmarshal(b, &want), f) {
+			continue
+		}
+		v, err := DecodeBytes(b, DecodeOptions{})
+		if assert.NoError(t, err, f) {
+			assert.Equal(t, &want, v, f)
+		}
+	}
+}
+
+func TestDecodeBytesEquivalence(t *testing.T) {
+	for _, doc := range []string{
+		// prolog, comments, entities and line breaks
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\r\n<!-- c --><VAST version='3.0'><Ad id=\"a&amp;b\" sequence=\" 2 \"><InLine>" +
+			"<AdTitle>x &lt;&#65;&#x42;&gt;\r\ny<!-- c --><![CDATA[ <z> ]]></AdTitle><Advertiser><b>ignored</b>adv</Advertiser>" +
+			"<Impression id=\"1\"></Impression><Impression/><Expires> 30 </Expires></InLine></Ad></VAST> trailing",
+		// namespaces, empty and duplicated elements
+		`<VAST xmlns="http://www.iab.com/VAST" xmlns:p="urn:p"><Ad><Wrapper fallbackOnNoAd="true" allowMultipleAds="0">` +
+			`<AdSystem version="1">a</AdSystem><AdSystem>b</AdSystem><VASTAdTagURI>u</VASTAdTagURI>` +
+			`<Creatives><Creative><Linear><Icons><Icon offset="10%" duration="00:00:05"><IconClicks><IconClickThrough>c</IconClickThrough></IconClicks></Icon></Icons></Linear></Creative></Creatives>` +
+			`<Creatives><Creative sequence="2"><NonLinearAds><NonLinear width="1" minSuggestedDuration="00:00:01"><NonLinearClickTracking>t</NonLinearClickTracking></NonLinear></NonLinearAds></Creative></Creatives>` +
+			`<Extensions><Extension p:type="x" xmlns:q="urn:q" q:a="1" z:b="2" a="3"><CustomTracking><Tracking event="e">u</Tracking></CustomTracking><Data>d</Data></Extension>` +
+			`<Extension/><Extension></Extension><Other/></Extensions></Wrapper></Ad></VAST>`,
+		// inline creatives
+		`<DAAST><Ad><InLine><Creatives><Creative id="c" AdID="ad"><Linear skipoffset="00:00:05.500"><Duration>00:00:30</Duration>` +
+			`<MediaFiles><MediaFile width="640" height="360" scalable="true" bitrate="500" type="video/mp4">m</MediaFile><InteractiveCreativeFile variableDuration="1">i</InteractiveCreativeFile></MediaFiles>` +
+			`<TrackingEvents><Tracking event="progress" offset="10%">p</Tracking><Other/></TrackingEvents><VideoClicks><ClickThrough id="t">c</ClickThrough><CustomClick>cc</CustomClick></VideoClicks>` +
+			`<AdParameters xmlEncoded="true">&lt;p/&gt;</AdParameters></Linear><CompanionAds required="all"><Companion width="300" adSlotId="s"><StaticResource creativeType="image/png">s</StaticResource>` +
+			`<CompanionClickTracking>1</CompanionClickTracking><CompanionClickTracking>2</CompanionClickTracking></Companion></CompanionAds><UniversalAdId idRegistry="r">u</UniversalAdId>` +
+			`<CreativeExtensions><CreativeExtension type="t">x</CreativeExtension></CreativeExtensions></Creative></Creatives>` +
+			`<AdVerifications><Verification vendor="v"><JavaScriptResource browserOptional="true">j</JavaScriptResource><VerificationParameters>p</VerificationParameters></Verification></AdVerifications>` +
+			`<Pricing model="cpm" currency="USD"> 1.5 </Pricing></InLine></Ad><Error>e</Error></DAAST>`,
+	} {
+		var want VAST
+		if !assert.NoError(t, xml.Unmarshal([]byte(doc), &want), doc) {
+			continue
+		}
+		v, err := DecodeBytes([]byte(doc), DecodeOptions{})
+		if assert.NoError(t, err, doc) {
+			assert.Equal(t, &want, v, doc)
+		}
+	}
+}
+
+func TestDecodeBytesErrors(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		"<VAST>",
+		"<VAST></Ad>",
+		"<VAST><Ad></VAST>",
+		`<VAST version="3.0></VAST>`,
+		`<VAST version=3.0></VAST>`,
+		`<VAST><Ad sequence="x"/></VAST>`,
+		`<VAST><Error>&unknown;</Error></VAST>`,
+		`<VAST><Error>&#0;</Error></VAST>`,
+		"<VAST><Error>\x01</Error></VAST>",
+		"<VAST><Error>\xff</Error></VAST>",
+		`<VAST><Error>]]></Error></VAST>`,
+		`<VAST><!-- a -- b --></VAST>`,
+		`<?xml version="1.0" encoding="ISO-8859-1"?><VAST/>`,
+	} {
+		_, err := DecodeBytes([]byte(doc), DecodeOptions{})
+		assert.Error(t, err, doc)
+		assert.Error(t, xml.Unmarshal([]byte(doc), &VAST{}), doc)
+	}
+
+	_, err := DecodeBytes([]byte(`<!DOCTYPE VAST><VAST/>`), DecodeOptions{})
+	assert.EqualError(t, err, "vast: DTD not allowed")
+	_, err = DecodeBytes([]byte(`<VAST><Ad><InLine><AdTitle>abcdef</AdTitle></InLine></Ad></VAST>`), DecodeOptions{MaxDepth: 3})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	_, err = DecodeBytes([]byte(`<VAST><Ad><InLine><AdTitle>abcdef</AdTitle></InLine></Ad></VAST>`), DecodeOptions{MaxElementSize: 5})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	_, err = DecodeBytes([]byte(`<VAST a="1" b="2"/>`), DecodeOptions{MaxAttributes: 1})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	_, err = DecodeBytes([]byte(inlineDoc), DecodeOptions{MaxSize: 10})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+}
+
+func BenchmarkDecode(b *testing.B) {
+	docs := loadFixtures(b)
+	for _, f := range []string{"testdata/vast_inline_linear.xml", "testdata/vast_wrapper_linear_1.xml", "testdata/spotx_vpaid.xml"} {
+		doc := docs[f]
+		name := filepath.Base(f)
+		b.Run(name+"/xml.Unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(doc)))
+			for i := 0; i < b.N; i++ {
+				var v VAST
+				if err := xml.Unmarshal(doc, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/DecodeBytes", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(doc)))
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeBytes(doc, DecodeOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package vast
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CompareOption alters the way two documents are compared by Equal and Diff.
+type CompareOption int
+
+const (
+	// IgnoreOrder makes repeated elements (Impressions, Creatives, Tracking
+	// events...) compare equal regardless of their relative order. Elements
+	// left without a match are reported with a path prefixed with "-" and
+	// indexed in a for removals, or prefixed with "+" and indexed in b for
+	// additions, e.g. -VAST.Ads[2] and +VAST.Ads[0].
+	IgnoreOrder CompareOption = 1 << iota
+	// IgnoreWhitespace trims and collapses whitespace in text values and raw
+	// XML payloads before comparing them.
+	IgnoreWhitespace
+)
+
+// Difference describes a single value that differs between two documents.
+type Difference struct {
+	// Path addresses the value using Go field names and slice indexes, e.g.
+	// Ads[0].InLine.Creatives[1].Linear.Duration. With IgnoreOrder, the path
+	// of an unmatched element starts with "-" if the index refers to the first
+	// document or "+" if it refers to the second one.
+	Path string
+	// A is the value found in the first document, or nil if absent.
+	A interface{}
+	// B is the value found in the second document, or nil if absent.
+	B interface{}
+}
+
+// String implements the fmt.Stringer interface.
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %v != %v", d.Path, d.A, d.B)
+}
+
+// Equal reports whether a and b describe the same VAST document.
+func Equal(a, b *VAST, opts ...CompareOption) bool {
+	return len(Diff(a, b, opts...)) == 0
+}
+
+// Diff returns the list of differences between a and b, addressed by path.
+// An empty result means that the two documents are equal.
+func Diff(a, b *VAST, opts ...CompareOption) []Difference {
+	var c comparer
+	for _, o := range opts {
+		c.opts |= o
+	}
+	c.diff("VAST", reflect.ValueOf(a), reflect.ValueOf(b))
+	return c.diffs
+}
+
+type comparer struct {
+	opts  CompareOption
+	diffs []Difference
+}
+
+func (c *comparer) add(path string, a, b reflect.Value) {
+	c.diffs = append(c.diffs, Difference{Path: path, A: valueOf(a), B: valueOf(b)})
+}
+
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return string(v.Bytes())
+	}
+	return v.Interface()
+}
+
+func (c *comparer) diff(path string, a, b reflect.Value) {
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				c.add(path, a, b)
+			}
+			return
+		}
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			c.add(path, a, b)
+			return
+		}
+		c.diff(path, a.Elem(), b.Elem())
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < a.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			c.diff(path+"."+t.Field(i).Name, a.Field(i), b.Field(i))
+		}
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			if c.text(string(a.Bytes())) != c.text(string(b.Bytes())) {
+				c.add(path, a, b)
+			}
+			return
+		}
+		if c.opts&IgnoreOrder != 0 {
+			c.diffUnordered(path, a, b)
+			return
+		}
+		n := a.Len()
+		if b.Len() > n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= a.Len():
+				c.add(p, reflect.Value{}, b.Index(i))
+			case i >= b.Len():
+				c.add(p, a.Index(i), reflect.Value{})
+			default:
+				c.diff(p, a.Index(i), b.Index(i))
+			}
+		}
+	case reflect.Map:
+		for _, k := range a.MapKeys() {
+			p := fmt.Sprintf("%s[%v]", path, k.Interface())
+			if bv := b.MapIndex(k); bv.IsValid() {
+				c.diff(p, a.MapIndex(k), bv)
+			} else {
+				c.add(p, a.MapIndex(k), reflect.Value{})
+			}
+		}
+		for _, k := range b.MapKeys() {
+			if !a.MapIndex(k).IsValid() {
+				c.add(fmt.Sprintf("%s[%v]", path, k.Interface()), reflect.Value{}, b.MapIndex(k))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			c.diff(path+"["+strconv.Itoa(i)+"]", a.Index(i), b.Index(i))
+		}
+	case reflect.String:
+		if c.text(a.String()) != c.text(b.String()) {
+			c.add(path, a, b)
+		}
+	default:
+		// values such as funcs, held by the interface fields of extensions,
+		// can't be compared with !=
+		if a.Type().Comparable() {
+			if a.Interface() != b.Interface() {
+				c.add(path, a, b)
+			}
+		} else if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			c.add(path, a, b)
+		}
+	}
+}
+
+// diffUnordered matches each element of a with an equal element of b and
+// reports the elements left unmatched on either side, prefixing their path
+// with the side their index refers to.
+func (c *comparer) diffUnordered(path string, a, b reflect.Value) {
+	matched := make([]bool, b.Len())
+	for i := 0; i < a.Len(); i++ {
+		found := false
+		for j := 0; j < b.Len(); j++ {
+			if matched[j] {
+				continue
+			}
+			sub := comparer{opts: c.opts}
+			sub.diff("", a.Index(i), b.Index(j))
+			if len(sub.diffs) == 0 {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			c.add("-"+path+"["+strconv.Itoa(i)+"]", a.Index(i), reflect.Value{})
+		}
+	}
+	for j, ok := range matched {
+		if !ok {
+			c.add("+"+path+"["+strconv.Itoa(j)+"]", reflect.Value{}, b.Index(j))
+		}
+	}
+}
+
+// text normalizes s according to the IgnoreWhitespace option. Whitespace
+// between two XML tags is dropped, other runs are collapsed to one space.
+func (c *comparer) text(s string) string {
+	if c.opts&IgnoreWhitespace == 0 {
+		return s
+	}
+	fields := strings.Fields(s)
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 && !(strings.HasSuffix(fields[i-1], ">") && strings.HasPrefix(f, "<")) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a, _, _, err := loadFixture("testdata/vast_inline_linear.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	b := a.Clone()
+	assert.True(t, Equal(a, b))
+	assert.Empty(t, Diff(a, b))
+
+	b.Ads[0].InLine.AdSystem.Version = "2.0"
+	b.Ads[0].InLine.Creatives[0].Linear.MediaFiles = nil
+	b.Ads[0].InLine.Errors = append(b.Ads[0].InLine.Errors, CDATAString{"http://myErrorURL/error3"})
+	assert.False(t, Equal(a, b))
+	assert.Equal(t, []Difference{
+		{Path: "VAST.Ads[0].InLine.AdSystem.Version", A: "1.0", B: "2.0"},
+		{Path: "VAST.Ads[0].InLine.Creatives[0].Linear.MediaFiles[0]", A: a.Ads[0].InLine.Creatives[0].Linear.MediaFiles[0], B: nil},
+		{Path: "VAST.Ads[0].InLine.Errors[2]", A: nil, B: CDATAString{"http://myErrorURL/error3"}},
+	}, Diff(a, b))
+	assert.Equal(t, "VAST.Ads[0].InLine.AdSystem.Version: 1.0 != 2.0", Diff(a, b)[0].String())
+}
+
+func TestDiffNilPointer(t *testing.T) {
+	a := &VAST{Ads: []Ad{{InLine: &InLine{}}}}
+	b := &VAST{Ads: []Ad{{}}}
+	if d := Diff(a, b); assert.Len(t, d, 1) {
+		assert.Equal(t, "VAST.Ads[0].InLine", d[0].Path)
+		assert.Nil(t, d[0].B)
+	}
+	assert.True(t, Equal(nil, nil))
+	assert.False(t, Equal(a, nil))
+}
+
+func TestEqualIgnoreOrder(t *testing.T) {
+	a := &VAST{Errors: []CDATAString{{"http://a"}, {"http://b"}}}
+	b := &VAST{Errors: []CDATAString{{"http://b"}, {"http://a"}}}
+	assert.False(t, Equal(a, b))
+	assert.True(t, Equal(a, b, IgnoreOrder))
+
+	b.Errors[0].CDATA = "http://c"
+	assert.Equal(t, []Difference{
+		{Path: "-VAST.Errors[1]", A: CDATAString{"http://b"}, B: nil},
+		{Path: "+VAST.Errors[0]", A: nil, B: CDATAString{"http://c"}},
+	}, Diff(a, b, IgnoreOrder))
+
+	// the indexes of removed and added elements refer to different documents
+	a = &VAST{Ads: []Ad{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+	b = &VAST{Ads: []Ad{{ID: "4"}, {ID: "1"}, {ID: "2"}}}
+	assert.Equal(t, []Difference{
+		{Path: "-VAST.Ads[2]", A: Ad{ID: "3"}, B: nil},
+		{Path: "+VAST.Ads[0]", A: nil, B: Ad{ID: "4"}},
+	}, Diff(a, b, IgnoreOrder))
+}
+
+func TestEqualIgnoreWhitespace(t *testing.T) {
+	a := &VAST{Ads: []Ad{{InLine: &InLine{
+		AdTitle:    CDATAString{"  My   Ad\n"},
+		Extensions: &[]Extension{{Type: "geo", Data: []byte("\n  <Country>US</Country>\n  <Bandwidth>3</Bandwidth>\n")}},
+	}}}}
+	b := &VAST{Ads: []Ad{{InLine: &InLine{
+		AdTitle:    CDATAString{"My Ad"},
+		Extensions: &[]Extension{{Type: "geo", Data: []byte("<Country>US</Country><Bandwidth>3</Bandwidth>")}},
+	}}}}
+	assert.False(t, Equal(a, b))
+	assert.True(t, Equal(a, b, IgnoreWhitespace))
+	assert.True(t, Equal(a, b, IgnoreWhitespace, IgnoreOrder))
+}
+
+func TestDiffUncomparableValues(t *testing.T) {
+	f := func() {}
+	newVAST := func(value interface{}) *VAST {
+		return &VAST{Ads: []Ad{{InLine: &InLine{Extensions: &[]Extension{{Type: "t", Value: value}}}}}}
+	}
+	assert.True(t, Equal(newVAST(map[string][]int{"a": {1}}), newVAST(map[string][]int{"a": {1}})))
+	assert.False(t, Equal(newVAST(map[string][]int{"a": {1}}), newVAST(map[string][]int{"a": {2}})))
+	assert.True(t, Equal(newVAST([2][]string{{"a"}, nil}), newVAST([2][]string{{"a"}, nil})))
+	assert.False(t, Equal(newVAST([2][]string{{"a"}, nil}), newVAST([2][]string{{"b"}, nil})))
+	assert.True(t, Equal(newVAST((func())(nil)), newVAST((func())(nil))))
+	assert.False(t, Equal(newVAST(f), newVAST(f)))
+	assert.False(t, Equal(newVAST(f), newVAST(map[string]int{})))
+}
@@ -0,0 +1,525 @@
+package vast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EncodeOptions defines how a document is rendered by Encode.
+type EncodeOptions struct {
+	// Version is the target VAST version (e.g. "2.0", "3.0" or "4.1"). When
+	// empty, the version of the document is used. Elements and attributes not
+	// defined by the target version are omitted.
+	Version string
+	// Indent, when not empty, pretty-prints the document with each nesting
+	// level indented by Indent. Elements with text content are kept on a
+	// single line.
+	Indent string
+	// Minify trims the whitespace surrounding text values and ignores Indent.
+	Minify bool
+}
+
+// Encode writes the XML encoding of v to w, preceded by the XML header.
+//
+// Unlike xml.Marshal, the output is canonical: elements are written in the
+// order defined by the schema of the target version, empty optional elements
+// are omitted, and text values are written as CDATA sections only when they
+// contain markup characters.
+//
+// A decoded DAAST document is encoded as a DAAST 1.0 document, with the
+// DAAST element names, unless a Version is given, in which case it is
+// converted to a VAST document of that version.
+func Encode(w io.Writer, v *VAST, opts EncodeOptions) error {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	root, err := parseNode(b)
+	if err != nil {
+		return err
+	}
+	version := opts.Version
+	if version == "" {
+		version = v.Version
+	}
+	for i, a := range root.attrs {
+		if a.Name.Local == "version" {
+			root.attrs[i].Value = version
+		}
+	}
+	e := &encoder{
+		w:       bufio.NewWriter(w),
+		version: parseVersion(version),
+		indent:  opts.Indent,
+		minify:  opts.Minify,
+	}
+	if e.minify {
+		e.indent = ""
+	}
+	if v.root.Local == "DAAST" && opts.Version == "" {
+		// DAAST 1.0 documents follow the VAST 3 schema
+		e.daast, e.version = true, 30
+	}
+	e.normalize(root)
+	if e.daast {
+		root.name.Local = "DAAST"
+	}
+	e.w.WriteString(strings.TrimSuffix(xml.Header, "\n"))
+	if e.indent != "" {
+		e.w.WriteByte('\n')
+	}
+	e.write(root, 0)
+	if e.indent != "" {
+		e.w.WriteByte('\n')
+	}
+	return e.w.Flush()
+}
+
+// node is an element of the document tree, or a text node if text is true.
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	text     bool
+	data     string
+	// raw is the content of an opaque element, such as an extension, copied
+	// from the marshaled document and written as is, CDATA sections
+	// included.
+	raw string
+}
+
+// parseNode returns the root element of the XML document b, keeping the
+// namespace prefixes as is and the content of the opaque elements raw.
+func parseNode(b []byte) (*node, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	doc := &node{}
+	stack := []*node{doc}
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attrs: t.Copy().Attr}
+			top.children = append(top.children, n)
+			if opaqueElements[t.Name.Local] {
+				start := d.InputOffset()
+				end, err := skipRaw(d)
+				if err != nil {
+					return nil, err
+				}
+				n.raw = string(b[start:end])
+				continue
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if l := len(top.children); l > 0 && top.children[l-1].text {
+				top.children[l-1].data += string(t)
+				continue
+			}
+			top.children = append(top.children, &node{text: true, data: string(t)})
+		}
+	}
+	for _, n := range doc.children {
+		if !n.text {
+			return n, nil
+		}
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// skipRaw skips the content of the element whose start tag was just read by
+// d, up to its end tag, and returns the offset of the end tag.
+func skipRaw(d *xml.Decoder) (int64, error) {
+	for depth := 1; ; {
+		off := d.InputOffset()
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth--; depth == 0 {
+				return off, nil
+			}
+		}
+	}
+}
+
+type encoder struct {
+	w       *bufio.Writer
+	version int
+	indent  string
+	minify  bool
+	// daast is true when encoding a DAAST document
+	daast bool
+}
+
+// normalize removes the elements and attributes of n not defined by the
+// target version, the empty optional elements defined by the schema and the
+// insignificant whitespace, and sorts its children in schema order. The
+// content of the extensions is left untouched.
+func (e *encoder) normalize(n *node) {
+	attrs := n.attrs[:0]
+	for _, a := range n.attrs {
+		if e.defined(n.name.Local+"@"+a.Name.Local) && !zeroAudioDimension(n, a) {
+			attrs = append(attrs, a)
+		}
+	}
+	n.attrs = attrs
+	if n.raw != "" {
+		return
+	}
+	hasElem := false
+	for _, c := range n.children {
+		if !c.text {
+			hasElem = true
+			break
+		}
+	}
+	children := n.children[:0]
+	for _, c := range n.children {
+		if c.text {
+			if e.minify {
+				c.data = strings.TrimSpace(c.data)
+			}
+			if c.data == "" || (hasElem && strings.TrimSpace(c.data) == "") {
+				continue
+			}
+		} else {
+			if !e.defined(n.name.Local + "/" + c.name.Local) {
+				continue
+			}
+			e.normalize(c)
+			empty := len(c.attrs) == 0 && len(c.children) == 0 && c.raw == ""
+			if empty && schemaElements[c.name.Local] && !requiredElements[n.name.Local+"/"+c.name.Local] {
+				continue
+			}
+			if name, ok := daastNames[n.name.Local+"/"+c.name.Local]; ok && e.daast {
+				c.name.Local = name
+			}
+		}
+		children = append(children, c)
+	}
+	n.children = children
+	order := schemaOrderV3
+	if e.version >= 40 {
+		order = schemaOrderV4
+	}
+	names, ok := order[n.name.Local]
+	if e.daast {
+		if daast, found := schemaOrderDAAST[n.name.Local]; found {
+			names, ok = daast, true
+		}
+	}
+	if ok {
+		rank := func(c *node) int {
+			if c.text {
+				return len(names)
+			}
+			for i, name := range names {
+				if c.name.Local == name {
+					return i
+				}
+			}
+			return len(names)
+		}
+		sort.SliceStable(n.children, func(i, j int) bool {
+			return rank(n.children[i]) < rank(n.children[j])
+		})
+	}
+}
+
+// zeroAudioDimension returns true if a is a zero width or height of an audio
+// media file n, which has no dimensions.
+func zeroAudioDimension(n *node, a xml.Attr) bool {
+	if n.name.Local != "MediaFile" || (a.Name.Local != "width" && a.Name.Local != "height") || a.Value != "0" {
+		return false
+	}
+	for _, t := range n.attrs {
+		if t.Name.Local == "type" {
+			return MediaFile{Type: t.Value}.IsAudio()
+		}
+	}
+	return false
+}
+
+// defined returns true if the element or attribute key (Parent/Child or
+// Element@attr) is defined by the target version.
+func (e *encoder) defined(key string) bool {
+	if e.daast && daastElements[key] {
+		return true
+	}
+	since, ok := introducedIn[key]
+	return !ok || e.version == 0 || e.version >= since
+}
+
+func (e *encoder) write(n *node, depth int) {
+	if n.text {
+		e.writeText(n.data)
+		return
+	}
+	e.w.WriteByte('<')
+	e.w.WriteString(qualifiedName(n.name))
+	for _, a := range n.attrs {
+		e.w.WriteByte(' ')
+		e.w.WriteString(qualifiedName(a.Name))
+		e.w.WriteString(`="`)
+		attrEscaper.WriteString(e.w, a.Value)
+		e.w.WriteByte('"')
+	}
+	if n.raw != "" {
+		e.w.WriteByte('>')
+		e.w.WriteString(n.raw)
+		e.w.WriteString("</")
+		e.w.WriteString(qualifiedName(n.name))
+		e.w.WriteByte('>')
+		return
+	}
+	if len(n.children) == 0 {
+		e.w.WriteString("/>")
+		return
+	}
+	e.w.WriteByte('>')
+	// Elements with text content are written on a single line so their
+	// value is not altered by the indentation.
+	indent := e.indent != ""
+	for _, c := range n.children {
+		if c.text {
+			indent = false
+			break
+		}
+	}
+	for _, c := range n.children {
+		if indent {
+			e.newline(depth + 1)
+		}
+		e.write(c, depth+1)
+	}
+	if indent {
+		e.newline(depth)
+	}
+	e.w.WriteString("</")
+	e.w.WriteString(qualifiedName(n.name))
+	e.w.WriteByte('>')
+}
+
+func (e *encoder) newline(depth int) {
+	e.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.w.WriteString(e.indent)
+	}
+}
+
+// writeText writes s as a CDATA section if it contains markup characters, or
+// as escaped text otherwise.
+func (e *encoder) writeText(s string) {
+	if strings.ContainsAny(s, "<>&") && !strings.Contains(s, "]]>") {
+		e.w.WriteString("<![CDATA[")
+		e.w.WriteString(s)
+		e.w.WriteString("]]>")
+		return
+	}
+	textEscaper.WriteString(e.w, s)
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// parseVersion returns the VAST version s as major*10+minor (e.g. 41 for
+// "4.1"), or 0 if s is not a valid version.
+func parseVersion(s string) int {
+	major, minor := strings.TrimSpace(s), "0"
+	if i := strings.IndexByte(major, '.'); i != -1 {
+		major, minor = major[:i], major[i+1:]
+	}
+	ma, err := strconv.Atoi(major)
+	if err != nil || ma < 0 {
+		return 0
+	}
+	mi, err := strconv.Atoi(minor)
+	if err != nil || mi < 0 || mi > 9 {
+		return 0
+	}
+	return ma*10 + mi
+}
+
+// introducedIn lists the elements (Parent/Child) and attributes
+// (Element@attr) by the version which introduced them, as returned by
+// parseVersion. Keys not listed are defined by all versions.
+var introducedIn = map[string]int{
+	"VAST/Error":                         30,
+	"Ad@sequence":                        30,
+	"InLine/Pricing":                     30,
+	"Wrapper/Pricing":                    30,
+	"Wrapper@fallbackOnNoAd":             30,
+	"Wrapper@allowMultipleAds":           30,
+	"Wrapper@followAdditionalWrappers":   30,
+	"Linear@skipoffset":                  30,
+	"Linear/Icons":                       30,
+	"Tracking@offset":                    30,
+	"MediaFile@codec":                    30,
+	"MediaFile@minBitrate":               30,
+	"MediaFile@maxBitrate":               30,
+	"Companion@assetWidth":               30,
+	"Companion@assetHeight":              30,
+	"Companion@adSlotId":                 30,
+	"Companion/CompanionClickTracking":   30,
+	"Creative/CreativeExtensions":        30,
+	"Ad@adType":                          41,
+	"InLine/Category":                    40,
+	"InLine/Expires":                     40,
+	"InLine/AdServingId":                 40,
+	"Creative/UniversalAdId":             40,
+	"MediaFiles/InteractiveCreativeFile": 40,
+	"MediaFiles/ClosedCaptionFiles":      41,
+	"InLine/AdVerifications":             41,
+	"Wrapper/AdVerifications":            41,
+}
+
+// daastElements lists the elements defined by DAAST 1.0 on top of the VAST 3
+// schema it is based on.
+var daastElements = map[string]bool{
+	"InLine/Category": true,
+	"InLine/Expires":  true,
+}
+
+// daastNames maps the elements (Parent/Child) renamed by DAAST 1.0 to their
+// DAAST name.
+var daastNames = map[string]string{
+	"Linear/VideoClicks":   "AudioInteractions",
+	"Wrapper/VASTAdTagURI": "DAASTAdTagURI",
+}
+
+// requiredElements lists the elements (Parent/Child) required by the schema,
+// written even when empty.
+var requiredElements = map[string]bool{
+	"InLine/AdSystem":      true,
+	"InLine/AdTitle":       true,
+	"Wrapper/AdSystem":     true,
+	"Wrapper/VASTAdTagURI": true,
+}
+
+// opaqueElements lists the elements whose content is a payload defined by a
+// third party, copied byte for byte from the marshaled document: it is
+// neither normalized nor indented, and its CDATA sections are kept.
+var opaqueElements = map[string]bool{
+	"Extension":         true,
+	"CreativeExtension": true,
+}
+
+// schemaElements lists the elements defined by the VAST schema, as encoded
+// from the VAST type. Only these elements are omitted when empty.
+var schemaElements = map[string]bool{"VAST": true, "DAAST": true}
+
+func init() {
+	addSchemaElements(reflect.TypeOf(VAST{}), map[reflect.Type]bool{})
+}
+
+// addSchemaElements adds to schemaElements the names of the elements encoded
+// from the fields of the struct type t.
+func addSchemaElements(t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Name == "XMLName" {
+			continue
+		}
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if i := strings.IndexByte(tag, ','); i != -1 {
+			name = tag[:i]
+			if strings.Contains(tag[i:], "attr") || strings.Contains(tag[i:], "chardata") ||
+				strings.Contains(tag[i:], "cdata") || strings.Contains(tag[i:], "innerxml") ||
+				strings.Contains(tag[i:], "any") {
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		for _, n := range strings.Split(name, ">") {
+			schemaElements[n] = true
+		}
+		addSchemaElements(f.Type, seen)
+	}
+}
+
+// schemaOrderV3 lists the children of the elements in the order defined by
+// the VAST 2.0 and 3.0 schemas.
+var schemaOrderV3 = map[string][]string{
+	"VAST":         {"Ad", "Error"},
+	"InLine":       {"AdSystem", "AdTitle", "Description", "Advertiser", "Pricing", "Survey", "Error", "Impression", "Creatives", "Extensions"},
+	"Wrapper":      {"AdSystem", "VASTAdTagURI", "Pricing", "Error", "Impression", "Creatives", "Extensions"},
+	"Creative":     {"CreativeExtensions", "Linear", "CompanionAds", "NonLinearAds"},
+	"Linear":       {"AdParameters", "Duration", "MediaFiles", "TrackingEvents", "VideoClicks", "Icons"},
+	"VideoClicks":  {"ClickThrough", "ClickTracking", "CustomClick"},
+	"Companion":    {"StaticResource", "IFrameResource", "HTMLResource", "TrackingEvents", "CompanionClickThrough", "CompanionClickTracking", "AltText", "AdParameters"},
+	"NonLinearAds": {"NonLinear", "TrackingEvents"},
+	"NonLinear":    {"StaticResource", "IFrameResource", "HTMLResource", "AdParameters", "NonLinearClickThrough", "NonLinearClickTracking"},
+	"Icon":         {"StaticResource", "IFrameResource", "HTMLResource", "IconClicks", "IconViewTracking"},
+	"IconClicks":   {"IconClickThrough", "IconClickTracking"},
+}
+
+// schemaOrderDAAST lists the children of the elements whose order in the
+// DAAST 1.0 schema differs from the VAST 3 one, by their DAAST names.
+var schemaOrderDAAST = map[string][]string{
+	"InLine":            {"AdSystem", "AdTitle", "Category", "Description", "Advertiser", "Pricing", "Survey", "Error", "Impression", "Creatives", "Expires", "Extensions"},
+	"Wrapper":           {"AdSystem", "DAASTAdTagURI", "Pricing", "Error", "Impression", "Creatives", "Extensions"},
+	"Linear":            {"AdParameters", "Duration", "MediaFiles", "AudioInteractions", "TrackingEvents"},
+	"AudioInteractions": {"ClickThrough", "ClickTracking", "CustomClick"},
+}
+
+// schemaOrderV4 lists the children of the elements in the order defined by
+// the VAST 4.x schemas.
+var schemaOrderV4 = map[string][]string{
+	"VAST":         {"Ad", "Error"},
+	"InLine":       {"AdSystem", "Error", "Extensions", "Impression", "Pricing", "AdServingId", "AdTitle", "AdVerifications", "Advertiser", "Category", "Creatives", "Description", "Expires", "Survey"},
+	"Wrapper":      {"AdSystem", "Error", "Extensions", "Impression", "Pricing", "AdVerifications", "Creatives", "VASTAdTagURI"},
+	"Creative":     {"CompanionAds", "CreativeExtensions", "Linear", "NonLinearAds", "UniversalAdId"},
+	"Linear":       {"Icons", "TrackingEvents", "AdParameters", "Duration", "MediaFiles", "VideoClicks"},
+	"MediaFiles":   {"ClosedCaptionFiles", "MediaFile", "InteractiveCreativeFile"},
+	"VideoClicks":  {"ClickThrough", "ClickTracking", "CustomClick"},
+	"Companion":    {"AdParameters", "AltText", "CompanionClickThrough", "CompanionClickTracking", "TrackingEvents", "HTMLResource", "IFrameResource", "StaticResource"},
+	"NonLinearAds": {"NonLinear", "TrackingEvents"},
+	"NonLinear":    {"AdParameters", "NonLinearClickThrough", "NonLinearClickTracking", "HTMLResource", "IFrameResource", "StaticResource"},
+	"Icon":         {"IconClicks", "IconViewTracking", "HTMLResource", "IFrameResource", "StaticResource"},
+	"IconClicks":   {"IconClickThrough", "IconClickTracking"},
+}
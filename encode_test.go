@@ -0,0 +1,176 @@
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEncodeVAST() *VAST {
+	skip := Duration(5 * time.Second)
+	return &VAST{Version: "3.0", Ads: []Ad{{ID: "1", Sequence: 1, InLine: &InLine{
+		AdSystem:    &AdSystem{Name: "sys"},
+		AdTitle:     CDATAString{" title "},
+		Impressions: []Impression{{URI: "http://imp?a=1&b=2"}},
+		Errors:      []CDATAString{{"http://err"}},
+		Pricing:     &Pricing{Model: "cpm", Currency: "USD", Value: "1"},
+		Creatives: []Creative{{
+			UniversalAdID: &UniversalAdID{IDRegistry: "reg", IDValue: "val", ID: "id"},
+			Linear: &Linear{
+				SkipOffset: &Offset{Duration: &skip},
+				Duration:   Duration(15 * time.Second),
+				Icons:      &Icons{},
+				MediaFiles: []MediaFile{{Delivery: "progressive", Type: "video/mp4", Width: 640, Height: 360, URI: "http://media"}},
+			},
+		}},
+	}}}}
+}
+
+func TestEncode(t *testing.T) {
+	var b bytes.Buffer
+	if !assert.NoError(t, Encode(&b, testEncodeVAST(), EncodeOptions{Indent: "  "})) {
+		return
+	}
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<VAST version="3.0">
+  <Ad id="1" sequence="1">
+    <InLine>
+      <AdSystem>sys</AdSystem>
+      <AdTitle> title </AdTitle>
+      <Pricing model="cpm" currency="USD">1</Pricing>
+      <Error>http://err</Error>
+      <Impression><![CDATA[http://imp?a=1&b=2]]></Impression>
+      <Creatives>
+        <Creative>
+          <Linear skipoffset="00:00:05">
+            <Duration>00:00:15</Duration>
+            <MediaFiles>
+              <MediaFile delivery="progressive" type="video/mp4" width="640" height="360">http://media</MediaFile>
+            </MediaFiles>
+          </Linear>
+        </Creative>
+      </Creatives>
+    </InLine>
+  </Ad>
+</VAST>
+`, b.String())
+}
+
+func TestEncodeVersion(t *testing.T) {
+	var b bytes.Buffer
+	if !assert.NoError(t, Encode(&b, testEncodeVAST(), EncodeOptions{Version: "2.0", Minify: true})) {
+		return
+	}
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<VAST version="2.0"><Ad id="1"><InLine><AdSystem>sys</AdSystem><AdTitle>title</AdTitle>`+
+		`<Error>http://err</Error><Impression><![CDATA[http://imp?a=1&b=2]]></Impression>`+
+		`<Creatives><Creative><Linear><Duration>00:00:15</Duration><MediaFiles>`+
+		`<MediaFile delivery="progressive" type="video/mp4" width="640" height="360">http://media</MediaFile>`+
+		`</MediaFiles></Linear></Creative></Creatives></InLine></Ad></VAST>`, b.String())
+
+	b.Reset()
+	if !assert.NoError(t, Encode(&b, testEncodeVAST(), EncodeOptions{Version: "4.1", Minify: true})) {
+		return
+	}
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<VAST version="4.1"><Ad id="1" sequence="1"><InLine><AdSystem>sys</AdSystem>`+
+		`<Error>http://err</Error><Impression><![CDATA[http://imp?a=1&b=2]]></Impression>`+
+		`<Pricing model="cpm" currency="USD">1</Pricing><AdTitle>title</AdTitle>`+
+		`<Creatives><Creative><Linear skipoffset="00:00:05"><Duration>00:00:15</Duration><MediaFiles>`+
+		`<MediaFile delivery="progressive" type="video/mp4" width="640" height="360">http://media</MediaFile>`+
+		`</MediaFiles></Linear><UniversalAdId idRegistry="reg" idValue="val">id</UniversalAdId></Creative></Creatives></InLine></Ad></VAST>`, b.String())
+}
+
+func TestEncodeCompanionWrapper(t *testing.T) {
+	v := &VAST{Version: "3.0", Ads: []Ad{{Wrapper: &Wrapper{
+		VASTAdTagURI: CDATAString{"http://next"},
+		Creatives: []CreativeWrapper{{CompanionAds: &CompanionAdsWrapper{
+			Companions: []CompanionWrapper{{ID: "c"}},
+		}}},
+	}}}}
+	var b bytes.Buffer
+	if assert.NoError(t, Encode(&b, v, EncodeOptions{})) {
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><VAST version="3.0"><Ad><Wrapper><VASTAdTagURI>http://next</VASTAdTagURI>`+
+			`<Creatives><Creative><CompanionAds><Companion id="c"/></CompanionAds></Creative></Creatives></Wrapper></Ad></VAST>`, b.String())
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, f := range []string{
+		"testdata/vast_inline_linear.xml",
+		"testdata/vast_inline_nonlinear.xml",
+		"testdata/inline_extensions.xml",
+		"testdata/creative_extensions.xml",
+		"testdata/vast4_simid.xml",
+	} {
+		v, _, _, err := loadFixture(f)
+		if !assert.NoError(t, err, f) {
+			continue
+		}
+		var b bytes.Buffer
+		if !assert.NoError(t, Encode(&b, v, EncodeOptions{Indent: "\t"}), f) {
+			continue
+		}
+		var v2 VAST
+		if assert.NoError(t, xml.Unmarshal(b.Bytes(), &v2), f) {
+			assert.Empty(t, Diff(v, &v2, IgnoreWhitespace), f)
+		}
+	}
+}
+
+func TestEncodeOpaqueExtension(t *testing.T) {
+	doc := `<VAST version="3.0"><Ad><InLine><AdSystem>sys</AdSystem>` +
+		`<Extensions><Extension type="vendor"><Flags><Skippable/></Flags><Creative><Linear/></Creative><Icons/></Extension>` +
+		`<Extension type="cdata"><Script><![CDATA[if (a < b) {}]]></Script>` + "\n  " + `<x:Raw xmlns:x="urn:x"> a </x:Raw></Extension></Extensions>` +
+		`<Creatives><Creative><CreativeExtensions><CreativeExtension type="c"><Ad><Error/><Wrapper/></Ad></CreativeExtension></CreativeExtensions></Creative></Creatives>` +
+		`</InLine></Ad></VAST>`
+	var v VAST
+	if !assert.NoError(t, xml.Unmarshal([]byte(doc), &v)) {
+		return
+	}
+	for _, opts := range []EncodeOptions{{}, {Indent: "  "}, {Minify: true}} {
+		var b bytes.Buffer
+		if !assert.NoError(t, Encode(&b, &v, opts)) {
+			continue
+		}
+		assert.Contains(t, b.String(), `<Extension type="vendor"><Flags><Skippable/></Flags><Creative><Linear/></Creative><Icons/></Extension>`)
+		assert.Contains(t, b.String(), `<CreativeExtension type="c"><Ad><Error/><Wrapper/></Ad></CreativeExtension>`)
+		assert.Contains(t, b.String(), `<Extension type="cdata"><Script><![CDATA[if (a < b) {}]]></Script>`+"\n  "+`<x:Raw xmlns:x="urn:x"> a </x:Raw></Extension>`)
+		var v2 VAST
+		if assert.NoError(t, xml.Unmarshal(b.Bytes(), &v2)) {
+			assert.Empty(t, Diff(&v, &v2))
+		}
+	}
+}
+
+func TestEncodeRequiredElements(t *testing.T) {
+	v := &VAST{Version: "3.0", Ads: []Ad{
+		{InLine: &InLine{AdSystem: &AdSystem{}}},
+		{Wrapper: &Wrapper{AdSystem: &AdSystem{}}},
+	}}
+	var b bytes.Buffer
+	if assert.NoError(t, Encode(&b, v, EncodeOptions{Minify: true})) {
+		assert.Equal(t, xml.Header[:len(xml.Header)-1]+`<VAST version="3.0">`+
+			`<Ad><InLine><AdSystem/><AdTitle/></InLine></Ad>`+
+			`<Ad><Wrapper><AdSystem/><VASTAdTagURI/></Wrapper></Ad></VAST>`, b.String())
+	}
+}
+
+func TestEncodeVersion41(t *testing.T) {
+	v := testEncodeVAST()
+	v.Ads[0].AdType = AdTypeAudio
+	v.Ads[0].InLine.Creatives[0].Linear.ClosedCaptionFiles = []ClosedCaptionFile{{Type: CaptionTypeWebVTT, Language: "en", URI: "http://cc"}}
+	for version, want := range map[string]bool{"3.0": false, "4.0": false, "4.1": true, "4.2": true} {
+		var b bytes.Buffer
+		if !assert.NoError(t, Encode(&b, v, EncodeOptions{Version: version}), version) {
+			continue
+		}
+		assert.Equal(t, want, strings.Contains(b.String(), `adType="audio"`), version)
+		assert.Equal(t, want, strings.Contains(b.String(), `<ClosedCaptionFiles><ClosedCaptionFile type="text/vtt" language="en">http://cc</ClosedCaptionFile></ClosedCaptionFiles>`), version)
+		assert.Equal(t, want, strings.Contains(b.String(), "ClosedCaption"), version)
+	}
+}
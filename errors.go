@@ -0,0 +1,67 @@
+package vast
+
+import "fmt"
+
+// ErrorCode is a VAST error code, reported to error tracking URLs through the
+// [ERRORCODE] macro.
+type ErrorCode int
+
+// VAST 3.0 error codes.
+const (
+	// XML parsing error.
+	ErrorCodeXMLParsing ErrorCode = 100
+	// VAST schema validation error.
+	ErrorCodeSchemaValidation ErrorCode = 101
+	// VAST version of response not supported.
+	ErrorCodeVersionNotSupported ErrorCode = 102
+	// Trafficking error. Video player received an ad type that it was not
+	// expecting and/or cannot display.
+	ErrorCodeTrafficking ErrorCode = 200
+	// Video player expecting different linearity.
+	ErrorCodeLinearity ErrorCode = 201
+	// Video player expecting different duration.
+	ErrorCodeDuration ErrorCode = 202
+	// Video player expecting different size.
+	ErrorCodeSize ErrorCode = 203
+	// General Wrapper error.
+	ErrorCodeWrapper ErrorCode = 300
+	// Timeout of VAST URI provided in Wrapper element, or of VAST URI provided
+	// in a subsequent Wrapper element.
+	ErrorCodeWrapperTimeout ErrorCode = 301
+	// Wrapper limit reached, as defined by the video player. Too many Wrapper
+	// responses have been received with no InLine response.
+	ErrorCodeWrapperLimit ErrorCode = 302
+	// No ads VAST response after one or more Wrappers.
+	ErrorCodeWrapperNoAds ErrorCode = 303
+	// General Linear error. Video player is unable to display the Linear Ad.
+	ErrorCodeLinear ErrorCode = 400
+	// File not found. Unable to find Linear/MediaFile from URI.
+	ErrorCodeFileNotFound ErrorCode = 401
+	// Timeout of MediaFile URI.
+	ErrorCodeMediaFileTimeout ErrorCode = 402
+	// Couldn't find MediaFile that is supported by this video player, based on
+	// the attributes of the MediaFile element.
+	ErrorCodeMediaFileNotSupported ErrorCode = 403
+	// Problem displaying MediaFile.
+	ErrorCodeMediaFileDisplay ErrorCode = 405
+	// General NonLinearAds error.
+	ErrorCodeNonLinear ErrorCode = 500
+	// General CompanionAds error.
+	ErrorCodeCompanion ErrorCode = 600
+	// Undefined Error.
+	ErrorCodeUndefined ErrorCode = 900
+	// General VPAID error.
+	ErrorCodeVPAID ErrorCode = 901
+)
+
+// Error is an error carrying the VAST error code to report to the error
+// tracking URLs.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("vast: error %d: %s", e.Code, e.Message)
+}
@@ -1,36 +1,50 @@
 package vast
 
-import "encoding/xml"
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
 
 // Extension represent arbitrary XML provided by the platform to extend the
 // VAST response or by custom trackers.
 type Extension struct {
 	Type           string     `xml:"type,attr,omitempty"`
+	Attrs          []xml.Attr `xml:",any,attr"`
 	CustomTracking []Tracking `xml:"CustomTracking>Tracking,omitempty"`
 	Data           []byte     `xml:",innerxml"`
+	// Value, if set, holds the typed representation of the extension, such as
+	// returned by Decode, and is encoded in place of Data and Attrs on
+	// marshal.
+	Value interface{} `xml:"-"`
 }
 
 // the extension type as a middleware in the encoding process.
 type extension Extension
 
-type extensionNoCT struct {
-	Type string `xml:"type,attr,omitempty"`
-	Data []byte `xml:",innerxml"`
-}
-
 // MarshalXML implements xml.Marshaler interface.
 func (e Extension) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
-	// create a temporary element from a wrapper Extension, copy what we need to
-	// it and return it's encoding.
-	var e2 interface{}
-	// if we have custom trackers, we should ignore the data, if not, then we
-	// should consider only the data.
+	// render the typed value, if any, in place of the raw data
+	if e.Value != nil {
+		if err := e.encodeValue(); err != nil {
+			return err
+		}
+	}
+	// the custom trackers are encoded first, followed by the rest of the data
+	// which never contains the <CustomTracking> element. The trackers are
+	// wrapped in their own element so that it gets closed before the data.
+	type customTracking struct {
+		Tracking []Tracking
+	}
+	e2 := struct {
+		Type           string          `xml:"type,attr,omitempty"`
+		Attrs          []xml.Attr      `xml:",any,attr"`
+		CustomTracking *customTracking `xml:",omitempty"`
+		Data           []byte          `xml:",innerxml"`
+	}{Type: e.Type, Attrs: e.Attrs, Data: e.Data}
 	if len(e.CustomTracking) > 0 {
-		e2 = extension{Type: e.Type, CustomTracking: e.CustomTracking}
-	} else {
-		e2 = extensionNoCT{Type: e.Type, Data: e.Data}
+		e2.CustomTracking = &customTracking{e.CustomTracking}
 	}
-
 	return enc.EncodeElement(e2, start)
 }
 
@@ -42,12 +56,53 @@ func (e *Extension) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error
 	if err := dec.DecodeElement(&e2, &start); err != nil {
 		return err
 	}
-	// copy the type and the customTracking
+	// copy the type, the attributes and the customTracking
 	e.Type = e2.Type
+	e.Attrs = e2.Attrs
 	e.CustomTracking = e2.CustomTracking
-	// copy the data only of customTracking is empty
-	if len(e.CustomTracking) == 0 {
-		e.Data = e2.Data
+	e.Data = e2.Data
+	// the custom trackers are already decoded, remove them from the data so
+	// they are not encoded twice.
+	if len(e.CustomTracking) > 0 {
+		e.Data = stripCustomTracking(e2.Data)
 	}
 	return nil
 }
+
+// stripCustomTracking returns the inner XML data without its top level
+// <CustomTracking> elements. If nothing but whitespace remains, nil is
+// returned. If the data can't be tokenized, nil is returned as well as the
+// custom trackers can't be told apart from the rest of the data.
+func stripCustomTracking(data []byte) []byte {
+	var out []byte
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth, last, skipFrom := 0, int64(0), int64(-1)
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && tok.Name.Local == "CustomTracking" {
+				skipFrom = offset
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 && skipFrom >= 0 {
+				out = append(out, data[last:skipFrom]...)
+				last, skipFrom = dec.InputOffset(), -1
+			}
+		}
+	}
+	out = append(out, data[last:]...)
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil
+	}
+	return out
+}
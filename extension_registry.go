@@ -0,0 +1,202 @@
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownExtension is returned by Extension.Decode when no type has been
+// registered for the extension.
+var ErrUnknownExtension = errors.New("vast: unknown extension")
+
+var (
+	extensionTypesMu sync.RWMutex
+	extensionTypes   = map[string]reflect.Type{
+		"geo":             reflect.TypeOf(GeoExtension{}),
+		"waterfall":       reflect.TypeOf(WaterfallExtension{}),
+		"Count":           reflect.TypeOf(CountExtension{}),
+		"iab-Count":       reflect.TypeOf(CountExtension{}),
+		"SpotX-Count":     reflect.TypeOf(CountExtension{}),
+		"LR-Pricing":      reflect.TypeOf(PricingExtension{}),
+		"AdVerifications": reflect.TypeOf(AdVerificationsExtension{}),
+	}
+)
+
+// RegisterExtension associates the Go type of v with the extensions whose type
+// attribute, or the name of the first element of their content, equals name.
+// The type is decoded as if it were the <Extension> element itself: XML
+// attributes of the extension and its child elements map to the struct fields.
+//
+// RegisterExtension is typically called from an init function and replaces
+// any type previously registered under the same name.
+func RegisterExtension(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	extensionTypesMu.Lock()
+	extensionTypes[name] = t
+	extensionTypesMu.Unlock()
+}
+
+// Decode decodes the extension into a new value of the type registered for it
+// and returns a pointer to this value. The extension is left untouched: to
+// have a modified value encoded back when the extension is marshaled, assign
+// it to e.Value, which replaces the raw data and attributes of the extension.
+//
+// The registered type is looked up by the extension type attribute first, then
+// by the name of the root element of the extension data.
+func (e *Extension) Decode() (interface{}, error) {
+	t := e.registeredType()
+	if t == nil {
+		return nil, ErrUnknownExtension
+	}
+	var b bytes.Buffer
+	b.WriteString("<Extension")
+	declared := 0
+	for _, attr := range e.Attrs {
+		name := attr.Name.Local
+		switch space := attr.Name.Space; {
+		case space == "":
+		case space == "xmlns":
+			name = "xmlns:" + name
+		case space == xmlNamespace:
+			name = "xml:" + name
+		default:
+			prefix := namespacePrefix(space, e.Attrs)
+			if prefix == "" {
+				// the namespace was declared by an ancestor, declare it again
+				declared++
+				prefix = fmt.Sprintf("ns%d", declared)
+				writeAttr(&b, "xmlns:"+prefix, space)
+			}
+			name = prefix + ":" + name
+		}
+		writeAttr(&b, name, attr.Value)
+	}
+	b.WriteByte('>')
+	b.Write(e.Data)
+	b.WriteString("</Extension>")
+
+	v := reflect.New(t).Interface()
+	if err := xml.Unmarshal(b.Bytes(), v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// xmlNamespace is the namespace of the attributes with the reserved xml
+// prefix, such as xml:lang.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// namespacePrefix returns the prefix of the namespace space of an attribute
+// in an element with the attributes attrs. xml.Unmarshal replaces the prefix
+// of an attribute with its namespace if declared, and keeps it otherwise. An
+// empty string is returned for a namespace declared out of attrs.
+func namespacePrefix(space string, attrs []xml.Attr) string {
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" && a.Value == space {
+			return a.Name.Local
+		}
+	}
+	if strings.ContainsAny(space, ":/") {
+		return ""
+	}
+	return space
+}
+
+func writeAttr(b *bytes.Buffer, name, value string) {
+	b.WriteByte(' ')
+	b.WriteString(name)
+	b.WriteString(`="`)
+	xml.EscapeText(b, []byte(value))
+	b.WriteByte('"')
+}
+
+func (e *Extension) registeredType() reflect.Type {
+	extensionTypesMu.RLock()
+	defer extensionTypesMu.RUnlock()
+	if t, ok := extensionTypes[e.Type]; ok {
+		return t
+	}
+	dec := xml.NewDecoder(bytes.NewReader(e.Data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return extensionTypes[se.Name.Local]
+		}
+	}
+}
+
+// encodeValue replaces the data and attributes of the extension with the
+// encoding of its typed value.
+func (e *Extension) encodeValue() error {
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Extension"`
+		Value   interface{}
+	}{Value: e.Value})
+	if err != nil {
+		return err
+	}
+	// the typed value is rendered as a child of a temporary element, unwrap it
+	// to get its attributes and content.
+	var v struct {
+		Value struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+			Data  []byte     `xml:",innerxml"`
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	e.Attrs = v.Value.Attrs
+	e.Data = v.Value.Data
+	return nil
+}
+
+// GeoExtension is the "geo" extension added by Google Ad Manager, describing
+// the location and bandwidth of the viewer.
+type GeoExtension struct {
+	Country       string `xml:"Country,omitempty"`
+	Bandwidth     int    `xml:"Bandwidth,omitempty"`
+	BandwidthKbps int    `xml:"BandwidthKbps,omitempty"`
+}
+
+// WaterfallExtension is the "waterfall" extension added by Google Ad Manager,
+// giving the position of the ad in the fallback waterfall.
+type WaterfallExtension struct {
+	FallbackIndex int `xml:"fallback_index,attr"`
+}
+
+// CountExtension is the "Count" extension used by Google, SpotX and the IAB
+// samples to give the number of ads available in the response.
+type CountExtension struct {
+	TotalAvailable int `xml:"total_available"`
+}
+
+// PricingExtension is the pricing extension used by SpotX (LR-Pricing).
+type PricingExtension struct {
+	Prices []ExtensionPrice `xml:"Price"`
+}
+
+// ExtensionPrice is a price advertised in a PricingExtension.
+type ExtensionPrice struct {
+	Model    string `xml:"model,attr,omitempty"`
+	Currency string `xml:"currency,attr,omitempty"`
+	Source   string `xml:"source,attr,omitempty"`
+	Value    string `xml:",cdata"`
+}
+
+// AdVerificationsExtension is the VAST 3 way of providing Open Measurement
+// verification scripts, using an "AdVerifications" typed extension.
+type AdVerificationsExtension struct {
+	Verifications []Verification `xml:"AdVerifications>Verification"`
+}
@@ -0,0 +1,128 @@
+package vast
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtensionDecodeBuiltin(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/inline_extensions.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	exts := *v.Ads[0].InLine.Extensions
+	geo, err := exts[0].Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &GeoExtension{Country: "US", Bandwidth: 3, BandwidthKbps: 1680}, geo)
+		// decoding leaves the extension untouched
+		assert.Nil(t, exts[0].Value)
+	}
+	_, err = exts[2].Decode()
+	assert.Equal(t, ErrUnknownExtension, err)
+
+	v, _, _, err = loadFixture("testdata/spotx_vpaid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	exts = *v.Ads[0].InLine.Extensions
+	pricing, err := exts[0].Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &PricingExtension{Prices: []ExtensionPrice{{Model: "CPM", Currency: "USD", Source: "spotxchange", Value: "3.06"}}}, pricing)
+	}
+	count, err := exts[1].Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &CountExtension{TotalAvailable: 1}, count)
+	}
+}
+
+func TestExtensionDecodeAttrs(t *testing.T) {
+	var e Extension
+	assert.NoError(t, xml.Unmarshal([]byte(`<Extension type="waterfall" fallback_index="2"/>`), &e))
+	w, err := e.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &WaterfallExtension{FallbackIndex: 2}, w)
+	}
+
+	w.(*WaterfallExtension).FallbackIndex = 3
+	e.Value = w
+	b, err := xml.Marshal(e)
+	assert.NoError(t, err)
+	assert.Equal(t, `<Extension type="waterfall" fallback_index="3"></Extension>`, string(b))
+}
+
+type testVendorExtension struct {
+	Score int `xml:"VendorScore>Value"`
+}
+
+func TestExtensionRegister(t *testing.T) {
+	RegisterExtension("VendorScore", &testVendorExtension{})
+	defer func() {
+		extensionTypesMu.Lock()
+		delete(extensionTypes, "VendorScore")
+		extensionTypesMu.Unlock()
+	}()
+
+	// lookup by root element name
+	e := Extension{Type: "vendor", Data: []byte(`<VendorScore><Value>12</Value></VendorScore>`)}
+	s, err := e.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &testVendorExtension{Score: 12}, s)
+	}
+
+	s.(*testVendorExtension).Score = 42
+	e.Value = s
+	b, err := xml.Marshal(e)
+	assert.NoError(t, err)
+	assert.Equal(t, `<Extension type="vendor"><VendorScore><Value>42</Value></VendorScore></Extension>`, string(b))
+}
+
+func TestExtensionDecodeAdVerifications(t *testing.T) {
+	e := Extension{Type: "AdVerifications", Data: []byte(`<AdVerifications><Verification vendor="moat.com-test"><JavaScriptResource apiFramework="omid" browserOptional="true"><![CDATA[https://js.moatads.com/moatvideo.js]]></JavaScriptResource><VerificationParameters><![CDATA[{"partnerCode":"test"}]]></VerificationParameters></Verification></AdVerifications>`)}
+	v, err := e.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &AdVerificationsExtension{Verifications: []Verification{{
+			Vendor:                 "moat.com-test",
+			JavaScriptResource:     []JavaScriptResource{{APIFramework: "omid", BrowserOptional: true, URI: "https://js.moatads.com/moatvideo.js"}},
+			VerificationParameters: &CDATAString{`{"partnerCode":"test"}`},
+		}}}, v)
+	}
+}
+
+type testNamespacedExtension struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Score int    `xml:"http://vendor.com/ns score,attr"`
+	Mode  string `xml:"http://other.com/ns mode,attr"`
+	Bar   string `xml:"http://vendor.com/ns Bar"`
+}
+
+func TestExtensionDecodeKeepsData(t *testing.T) {
+	doc := `<Extension type="geo"><Country>US</Country><Extra a="1">x</Extra><x:Bar xmlns:x="http://x"/></Extension>`
+	var e Extension
+	assert.NoError(t, xml.Unmarshal([]byte(doc), &e))
+	geo, err := e.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &GeoExtension{Country: "US"}, geo)
+	}
+	assert.Nil(t, e.Value)
+	b, err := xml.Marshal(e)
+	assert.NoError(t, err)
+	assert.Equal(t, doc, string(b))
+
+	RegisterExtension("vendor", &testNamespacedExtension{})
+	defer func() {
+		extensionTypesMu.Lock()
+		delete(extensionTypes, "vendor")
+		extensionTypesMu.Unlock()
+	}()
+	var v struct {
+		Extension Extension
+	}
+	doc = `<VAST xmlns:o="http://other.com/ns"><Extension type="vendor" xmlns:v="http://vendor.com/ns" v:score="3" o:mode="m" xml:lang="fr"><v:Bar>b</v:Bar></Extension></VAST>`
+	assert.NoError(t, xml.Unmarshal([]byte(doc), &v))
+	ns, err := v.Extension.Decode()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &testNamespacedExtension{Lang: "fr", Score: 3, Mode: "m", Bar: "b"}, ns)
+	}
+}
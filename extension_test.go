@@ -10,6 +10,7 @@ import (
 var (
 	extensionCustomTracking = []byte(`<Extension type="testCustomTracking"><CustomTracking><Tracking event="event.1"><![CDATA[http://event.1]]></Tracking><Tracking event="event.2"><![CDATA[http://event.2]]></Tracking></CustomTracking></Extension>`)
 	extensionData           = []byte(`<Extension type="testCustomTracking"><SkippableAdType>Generic</SkippableAdType></Extension>`)
+	extensionMixed          = []byte(`<Extension type="testCustomTracking"><SkippableAdType>Generic</SkippableAdType><CustomTracking><Tracking event="event.1"><![CDATA[http://event.1]]></Tracking></CustomTracking><Vendor><CustomTracking/></Vendor></Extension>`)
 )
 
 func TestExtensionCustomTrackingMarshal(t *testing.T) {
@@ -77,3 +78,29 @@ func TestExtensionGeneric(t *testing.T) {
 	// assert the resulting marshaled extension
 	assert.Equal(t, string(extensionData), string(xmlExtensionOutput))
 }
+
+func TestExtensionMixed(t *testing.T) {
+	// unmarshal the Extension
+	var e Extension
+	assert.NoError(t, xml.Unmarshal(extensionMixed, &e))
+
+	// assert the resulting extension keeps both the trackers and the data
+	assert.Equal(t, "testCustomTracking", e.Type)
+	assert.Equal(t, "<SkippableAdType>Generic</SkippableAdType><Vendor><CustomTracking/></Vendor>", string(e.Data))
+	if assert.Len(t, e.CustomTracking, 1) {
+		assert.Equal(t, "event.1", e.CustomTracking[0].Event)
+		assert.Equal(t, "http://event.1", e.CustomTracking[0].URI)
+	}
+
+	// marshal the extension
+	xmlExtensionOutput, err := xml.Marshal(e)
+	assert.NoError(t, err)
+
+	// assert the custom trackers are encoded once, followed by the data
+	assert.Equal(t, `<Extension type="testCustomTracking"><CustomTracking><Tracking event="event.1"><![CDATA[http://event.1]]></Tracking></CustomTracking><SkippableAdType>Generic</SkippableAdType><Vendor><CustomTracking/></Vendor></Extension>`, string(xmlExtensionOutput))
+
+	// a second round trip is stable
+	var e2 Extension
+	assert.NoError(t, xml.Unmarshal(xmlExtensionOutput, &e2))
+	assert.Equal(t, e, e2)
+}
@@ -0,0 +1,119 @@
+//go:build go1.18
+
+package vast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// fixtures returns the content of the XML documents of testdata.
+func fixtures(f *testing.F) [][]byte {
+	files, err := filepath.Glob("testdata/*.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	var docs [][]byte
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		docs = append(docs, b)
+	}
+	return docs
+}
+
+// addMatches seeds f with the first submatch of re found in the fixtures.
+func addMatches(f *testing.F, re *regexp.Regexp) {
+	for _, b := range fixtures(f) {
+		for _, m := range re.FindAllSubmatch(b, -1) {
+			f.Add(m[1])
+		}
+	}
+}
+
+func FuzzDurationUnmarshalText(f *testing.F) {
+	addMatches(f, regexp.MustCompile(`<Duration>\s*([^<]*?)\s*</Duration>`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var d Duration
+		if err := d.UnmarshalText(data); err != nil {
+			return
+		}
+		b, err := d.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d2 Duration
+		if err := d2.UnmarshalText(b); err != nil || d2 != d {
+			t.Fatalf("%q decoded as %v, re-encoded as %q decoded as %v (%v)", data, d, b, d2, err)
+		}
+	})
+}
+
+func FuzzOffsetUnmarshalText(f *testing.F) {
+	addMatches(f, regexp.MustCompile(`(?:offset|skipoffset)="([^"]*)"`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var o Offset
+		if err := o.UnmarshalText(data); err != nil {
+			return
+		}
+		if _, err := o.MarshalText(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzExtensionUnmarshalXML(f *testing.F) {
+	addMatches(f, regexp.MustCompile(`(?s)(<Extension\b.*?</Extension>)`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e Extension
+		if err := xml.Unmarshal(data, &e); err != nil {
+			return
+		}
+		if _, err := xml.Marshal(e); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, b := range fixtures(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Decode(bytes.NewReader(data), DecodeOptions{})
+		if err != nil {
+			return
+		}
+		xml.Marshal(v)
+	})
+}
+
+func FuzzDecodeBytes(f *testing.F) {
+	for _, b := range fixtures(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := DecodeBytes(data, DecodeOptions{})
+		var want VAST
+		if xml.Unmarshal(data, &want) != nil {
+			return
+		}
+		if errors.Is(err, ErrDecodeLimit) || err == errDTD {
+			return
+		}
+		if err != nil {
+			t.Fatalf("DecodeBytes: %v", err)
+		}
+		if !reflect.DeepEqual(&want, v) {
+			t.Fatalf("DecodeBytes differs from xml.Unmarshal:\n%#v\n%#v", &want, v)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Package hls turns resolved VAST ad pods into HLS interstitials for server
+// side ad insertion.
+//
+// A break is rendered as an #EXT-X-DATERANGE tag of class
+// "com.apple.hls.interstitial" pointing either to the HLS stream of a single
+// ad (X-ASSET-URI) or to an asset list (X-ASSET-LIST) describing all the ads
+// of the pod, along with their tracking URLs.
+package hls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/vast"
+)
+
+// InterstitialClass is the CLASS attribute of HLS interstitial date ranges.
+const InterstitialClass = "com.apple.hls.interstitial"
+
+// MediaTypes are the MIME types of HLS media files.
+var MediaTypes = []string{"application/x-mpegURL", "application/vnd.apple.mpegurl", "audio/mpegurl"}
+
+// ErrNoAsset is returned when no ad of the pod has an HLS media file.
+var ErrNoAsset = errors.New("hls: no ad with an HLS streaming media file")
+
+// TrackingEvents are the linear tracking events exported in the asset list.
+var TrackingEvents = []string{"start", "firstQuartile", "midpoint", "thirdQuartile", "complete"}
+
+// Options defines how a break is rendered.
+type Options struct {
+	// ID of the date range, must be unique in the playlist.
+	ID string
+	// AssetListURI is the URI at which the asset list JSON is served. When
+	// set, the date range points to the asset list (X-ASSET-LIST), otherwise
+	// the pod must contain a single ad which is referenced directly
+	// (X-ASSET-URI).
+	AssetListURI string
+	// ResumeOffset is the offset from the break position at which the
+	// primary content resumes (X-RESUME-OFFSET). When nil, the attribute is
+	// omitted and the player resumes after the duration of the break.
+	ResumeOffset *time.Duration
+	// Restrict lists the navigation restrictions (X-RESTRICT), such as "SKIP"
+	// or "JUMP".
+	Restrict []string
+}
+
+// Asset is an ad of the break, as exported in the asset list.
+type Asset struct {
+	// URI of the HLS stream of the ad.
+	URI string `json:"URI"`
+	// Duration of the ad in seconds.
+	Duration float64 `json:"DURATION"`
+	// AdID is the identifier of the VAST ad.
+	AdID string `json:"X-AD-ID,omitempty"`
+	// Tracking holds the tracking URLs of the ad by event. The impression and
+	// error URLs are listed under the "impression" and "error" keys.
+	Tracking map[string][]string `json:"X-AD-TRACKING,omitempty"`
+}
+
+// Break is an ad break rendered as an HLS interstitial.
+type Break struct {
+	Options
+	// Start is the date of the break in the program timeline.
+	Start time.Time
+	// Assets of the break, in play order.
+	Assets []Asset
+	// Skipped lists the ids of the ads which had no HLS media file.
+	Skipped []string
+}
+
+// NewBreak creates the break for the ads of v starting at start. The ads of
+// the pod are used in sequence order; if v has no pod, its first stand-alone
+// ad is used. For each ad, the first linear creative with a streaming HLS
+// media file is selected. ErrNoAsset is returned for a nil document.
+func NewBreak(v *vast.VAST, start time.Time, opts Options) (*Break, error) {
+	if v == nil {
+		return nil, ErrNoAsset
+	}
+	ads := v.Pod()
+	if len(ads) == 0 {
+		if buffet := v.Buffet(); len(buffet) > 0 {
+			ads = buffet[:1]
+		}
+	}
+	b := &Break{Options: opts, Start: start}
+	sel := vast.MediaSelector{Types: MediaTypes, Delivery: "streaming"}
+	for i := range ads {
+		asset, ok := newAsset(&ads[i], sel)
+		if !ok {
+			b.Skipped = append(b.Skipped, ads[i].ID)
+			continue
+		}
+		b.Assets = append(b.Assets, asset)
+	}
+	if len(b.Assets) == 0 {
+		return nil, ErrNoAsset
+	}
+	if opts.AssetListURI == "" && len(b.Assets) > 1 {
+		return nil, fmt.Errorf("hls: an asset list URI is required for a break of %d ads", len(b.Assets))
+	}
+	return b, nil
+}
+
+func newAsset(ad *vast.Ad, sel vast.MediaSelector) (Asset, bool) {
+	if ad.InLine == nil {
+		return Asset{}, false
+	}
+	for _, c := range ad.InLine.Creatives {
+		if c.Linear == nil {
+			continue
+		}
+		m, ok := sel.Select(c.Linear.MediaFiles)
+		if !ok {
+			continue
+		}
+		a := Asset{
+			URI:      strings.TrimSpace(m.URI),
+			Duration: time.Duration(c.Linear.Duration).Seconds(),
+			AdID:     ad.ID,
+			Tracking: map[string][]string{},
+		}
+		if urls := ad.ImpressionURLs(); len(urls) > 0 {
+			a.Tracking["impression"] = urls
+		}
+		if urls := ad.ErrorURLs(); len(urls) > 0 {
+			a.Tracking["error"] = urls
+		}
+		for _, e := range TrackingEvents {
+			if urls := ad.TrackingURLs(e); len(urls) > 0 {
+				a.Tracking[e] = urls
+			}
+		}
+		return a, true
+	}
+	return Asset{}, false
+}
+
+// Duration returns the total duration of the break.
+func (b *Break) Duration() time.Duration {
+	var d float64
+	for _, a := range b.Assets {
+		d += a.Duration
+	}
+	return time.Duration(d * float64(time.Second))
+}
+
+// Tag returns the #EXT-X-DATERANGE tag of the break.
+func (b *Break) Tag() string {
+	attrs := []string{
+		"ID=" + quote(b.ID),
+		"CLASS=" + quote(InterstitialClass),
+		"START-DATE=" + quote(b.Start.UTC().Format("2006-01-02T15:04:05.000Z07:00")),
+		"DURATION=" + seconds(b.Duration()),
+	}
+	if b.AssetListURI != "" {
+		attrs = append(attrs, "X-ASSET-LIST="+quote(b.AssetListURI))
+	} else {
+		attrs = append(attrs, "X-ASSET-URI="+quote(b.Assets[0].URI))
+	}
+	if b.ResumeOffset != nil {
+		attrs = append(attrs, "X-RESUME-OFFSET="+seconds(*b.ResumeOffset))
+	}
+	if len(b.Restrict) > 0 {
+		attrs = append(attrs, "X-RESTRICT="+quote(strings.Join(b.Restrict, ",")))
+	}
+	return "#EXT-X-DATERANGE:" + strings.Join(attrs, ",")
+}
+
+// AssetList returns the asset list JSON document of the break.
+func (b *Break) AssetList() ([]byte, error) {
+	return json.Marshal(struct {
+		Assets []Asset `json:"ASSETS"`
+	}{b.Assets})
+}
+
+// quote returns s as an HLS quoted-string. Double quotes and line feeds are
+// not allowed in quoted-strings and are removed.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "", "\n", "", "\r", "").Replace(s) + `"`
+}
+
+// seconds formats d as a decimal number of seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
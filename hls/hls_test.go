@@ -0,0 +1,71 @@
+package hls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/vast"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAd(id string, seq int, dur time.Duration, files ...vast.MediaFile) vast.Ad {
+	return vast.Ad{ID: id, Sequence: seq, InLine: &vast.InLine{
+		Impressions: []vast.Impression{{URI: "http://track/" + id + "/impression"}},
+		Creatives: []vast.Creative{{Linear: &vast.Linear{
+			Duration:       vast.Duration(dur),
+			TrackingEvents: []vast.Tracking{{Event: "start", URI: "http://track/" + id + "/start"}},
+			MediaFiles:     files,
+		}}},
+	}}
+}
+
+var (
+	hlsFile  = vast.MediaFile{Delivery: "streaming", Type: "application/x-mpegURL", URI: "https://cdn/ad.m3u8"}
+	mp4File  = vast.MediaFile{Delivery: "progressive", Type: "video/mp4", URI: "https://cdn/ad.mp4"}
+	start, _ = time.Parse(time.RFC3339, "2020-01-02T21:55:44Z")
+)
+
+func TestBreakSingleAd(t *testing.T) {
+	v := &vast.VAST{Version: "3.0", Ads: []vast.Ad{testAd("ad1", 0, 15*time.Second, mp4File, hlsFile)}}
+	resume := time.Duration(0)
+	b, err := NewBreak(v, start, Options{ID: "break-1", ResumeOffset: &resume, Restrict: []string{"SKIP", "JUMP"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 15*time.Second, b.Duration())
+	assert.Equal(t, `#EXT-X-DATERANGE:ID="break-1",CLASS="com.apple.hls.interstitial",START-DATE="2020-01-02T21:55:44.000Z",DURATION=15,X-ASSET-URI="https://cdn/ad.m3u8",X-RESUME-OFFSET=0,X-RESTRICT="SKIP,JUMP"`, b.Tag())
+}
+
+func TestBreakPod(t *testing.T) {
+	v := &vast.VAST{Version: "3.0", Ads: []vast.Ad{
+		testAd("ad2", 2, 10500*time.Millisecond, hlsFile),
+		testAd("ad1", 1, 15*time.Second, hlsFile),
+		testAd("noHLS", 3, 15*time.Second, mp4File),
+		testAd("buffet", 0, 30*time.Second, hlsFile),
+	}}
+	_, err := NewBreak(v, start, Options{ID: "break-2"})
+	assert.Error(t, err)
+
+	b, err := NewBreak(v, start, Options{ID: "break-2", AssetListURI: "https://ssai/break-2.json"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"noHLS"}, b.Skipped)
+	assert.Equal(t, `#EXT-X-DATERANGE:ID="break-2",CLASS="com.apple.hls.interstitial",START-DATE="2020-01-02T21:55:44.000Z",DURATION=25.5,X-ASSET-LIST="https://ssai/break-2.json"`, b.Tag())
+	list, err := b.AssetList()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"ASSETS":[
+		{"URI":"https://cdn/ad.m3u8","DURATION":15,"X-AD-ID":"ad1","X-AD-TRACKING":{"impression":["http://track/ad1/impression"],"start":["http://track/ad1/start"]}},
+		{"URI":"https://cdn/ad.m3u8","DURATION":10.5,"X-AD-ID":"ad2","X-AD-TRACKING":{"impression":["http://track/ad2/impression"],"start":["http://track/ad2/start"]}}
+	]}`, string(list))
+}
+
+func TestBreakNoAsset(t *testing.T) {
+	v := &vast.VAST{Version: "3.0", Ads: []vast.Ad{testAd("ad1", 0, 15*time.Second, mp4File)}}
+	_, err := NewBreak(v, start, Options{ID: "break-3"})
+	assert.Equal(t, ErrNoAsset, err)
+	_, err = NewBreak(&vast.VAST{}, start, Options{ID: "break-3"})
+	assert.Equal(t, ErrNoAsset, err)
+	_, err = NewBreak(nil, start, Options{ID: "break-3"})
+	assert.Equal(t, ErrNoAsset, err)
+}
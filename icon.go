@@ -0,0 +1,143 @@
+package vast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IconProgramAdChoices is the program of the AdChoices icons.
+const IconProgramAdChoices = "AdChoices"
+
+// IconPlacement is the position of an icon in the player, in pixels from the
+// top left corner, and whether it is visible at a given playhead.
+type IconPlacement struct {
+	X, Y          int
+	Width, Height int
+	Visible       bool
+}
+
+// Overlaps returns true if the rectangles of p and o intersect.
+func (p IconPlacement) Overlaps(o IconPlacement) bool {
+	return p.X < o.X+o.Width && o.X < p.X+p.Width &&
+		p.Y < o.Y+o.Height && o.Y < p.Y+p.Height
+}
+
+// ResolveIcon returns the placement of icon in a player of playerW x playerH
+// pixels when the creative is at the playhead position. The icon is visible
+// from its offset and for its duration, or until the end of the creative if
+// it has none. The icon is kept inside the player.
+func ResolveIcon(icon Icon, playerW, playerH int, playhead Duration) IconPlacement {
+	p := IconPlacement{Width: icon.Width, Height: icon.Height}
+	p.X = iconPosition(icon.XPosition, "left", "right", playerW, icon.Width)
+	p.Y = iconPosition(icon.YPosition, "top", "bottom", playerH, icon.Height)
+	var start Duration
+	if icon.Offset.Duration != nil {
+		start = *icon.Offset.Duration
+	}
+	p.Visible = playhead >= start && (icon.Duration == 0 || playhead < start+icon.Duration)
+	return p
+}
+
+// iconPosition returns the pixel position of an icon of the given size along
+// an axis of the player of length max, pos being either a pixel position or
+// one of the start and end keywords.
+func iconPosition(pos, start, end string, max, size int) int {
+	var x int
+	switch p := strings.ToLower(strings.TrimSpace(pos)); p {
+	case start:
+		return 0
+	case end:
+		x = max - size
+	default:
+		x, _ = strconv.Atoi(p)
+	}
+	if x > max-size {
+		x = max - size
+	}
+	if x < 0 {
+		x = 0
+	}
+	return x
+}
+
+// ResolvedIcon is an icon along with its placement.
+type ResolvedIcon struct {
+	Icon      Icon
+	Placement IconPlacement
+}
+
+// ResolveIcons returns the visible icons to display at the playhead position.
+// The icons must be ordered by precedence: the icons of the inline ad first,
+// then those of the wrappers from the closest to the inline ad to the
+// outermost one, as returned by ChainIcons. When two icons of the same
+// program overlap, only the one with the highest precedence is kept.
+func ResolveIcons(icons []Icon, playerW, playerH int, playhead Duration) []ResolvedIcon {
+	var res []ResolvedIcon
+	for _, icon := range icons {
+		p := ResolveIcon(icon, playerW, playerH, playhead)
+		if !p.Visible {
+			continue
+		}
+		conflict := false
+		for _, r := range res {
+			if strings.EqualFold(r.Icon.Program, icon.Program) && r.Placement.Overlaps(p) {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			res = append(res, ResolvedIcon{Icon: icon, Placement: p})
+		}
+	}
+	return res
+}
+
+// ChainIcons returns the icons of the linear creatives of chain, a chain of
+// ads ordered from the outermost wrapper to the inline ad. The icons are
+// returned by precedence: those of the inline ad first, then those of the
+// wrappers from the last to the first.
+func ChainIcons(chain []Ad) []Icon {
+	var icons []Icon
+	for i := len(chain) - 1; i >= 0; i-- {
+		ad := &chain[i]
+		if ad.InLine != nil {
+			for _, c := range ad.InLine.Creatives {
+				if c.Linear != nil && c.Linear.Icons != nil {
+					icons = append(icons, c.Linear.Icons.Icon...)
+				}
+			}
+		}
+		if ad.Wrapper != nil {
+			for _, c := range ad.Wrapper.Creatives {
+				if c.Linear != nil && c.Linear.Icons != nil {
+					icons = append(icons, c.Linear.Icons.Icon...)
+				}
+			}
+		}
+	}
+	return icons
+}
+
+// RequireIcons checks that icons contains an icon for each of the given
+// programs (e.g. IconProgramAdChoices), compared case insensitively. It
+// returns a trafficking error listing the missing programs otherwise.
+func RequireIcons(icons []Icon, programs ...string) error {
+	var missing []string
+	for _, prog := range programs {
+		found := false
+		for _, icon := range icons {
+			if strings.EqualFold(strings.TrimSpace(icon.Program), prog) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, prog)
+		}
+	}
+	if len(missing) > 0 {
+		return &Error{Code: ErrorCodeTrafficking, Message: fmt.Sprintf("missing required icon: %s", strings.Join(missing, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package vast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveIcon(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast_adaptv_attempt_attr.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	icon := v.Ads[0].InLine.Creatives[0].Linear.Icons.Icon[0]
+	assert.Equal(t, IconPlacement{X: 563, Y: 0, Width: 77, Height: 15, Visible: true},
+		ResolveIcon(icon, 640, 360, Duration(time.Second)))
+
+	off := Duration(5 * time.Second)
+	icon = Icon{Width: 20, Height: 10, XPosition: "30", YPosition: "bottom", Offset: Offset{Duration: &off}, Duration: Duration(10 * time.Second)}
+	assert.Equal(t, IconPlacement{X: 30, Y: 350, Width: 20, Height: 10}, ResolveIcon(icon, 640, 360, Duration(time.Second)))
+	assert.True(t, ResolveIcon(icon, 640, 360, Duration(5*time.Second)).Visible)
+	assert.False(t, ResolveIcon(icon, 640, 360, Duration(15*time.Second)).Visible)
+
+	icon.XPosition = "1000"
+	assert.Equal(t, 620, ResolveIcon(icon, 640, 360, 0).X)
+	icon.XPosition = "bogus"
+	assert.Equal(t, 0, ResolveIcon(icon, 640, 360, 0).X)
+}
+
+func TestResolveIcons(t *testing.T) {
+	icon := func(prog, x string) Icon {
+		return Icon{Program: prog, Width: 20, Height: 20, XPosition: x, YPosition: "top"}
+	}
+	chain := []Ad{
+		{Wrapper: &Wrapper{Creatives: []CreativeWrapper{{Linear: &LinearWrapper{Icons: &Icons{Icon: []Icon{
+			icon("AdChoices", "right"), icon("Other", "left"),
+		}}}}}}},
+		{Wrapper: &Wrapper{Creatives: []CreativeWrapper{{Linear: &LinearWrapper{Icons: &Icons{Icon: []Icon{
+			icon("adchoices", "610"),
+		}}}}}}},
+		{InLine: &InLine{Creatives: []Creative{{Linear: &Linear{Icons: &Icons{Icon: []Icon{
+			icon("AdChoices", "0"),
+		}}}}}}},
+	}
+	icons := ChainIcons(chain)
+	assert.Equal(t, []string{"0", "610", "right", "left"}, []string{icons[0].XPosition, icons[1].XPosition, icons[2].XPosition, icons[3].XPosition})
+
+	res := ResolveIcons(icons, 640, 360, 0)
+	if assert.Len(t, res, 3) {
+		assert.Equal(t, "0", res[0].Icon.XPosition)
+		assert.Equal(t, "610", res[1].Icon.XPosition)
+		assert.Equal(t, "Other", res[2].Icon.Program)
+	}
+}
+
+func TestRequireIcons(t *testing.T) {
+	icons := []Icon{{Program: "adchoices"}}
+	assert.NoError(t, RequireIcons(icons, IconProgramAdChoices))
+	err := RequireIcons(nil, IconProgramAdChoices, "DAA")
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeTrafficking, err.(*Error).Code)
+		assert.EqualError(t, err, "vast: error 200: missing required icon: AdChoices, DAA")
+	}
+}
@@ -0,0 +1,208 @@
+package vast
+
+import "strings"
+
+// MediaKind classifies a creative or a media file by the technology needed to
+// render it.
+type MediaKind int
+
+const (
+	// MediaKindVideo is a plain video file, playable by any player.
+	MediaKindVideo MediaKind = iota
+	// MediaKindVPAIDJS is a JavaScript VPAID unit.
+	MediaKindVPAIDJS
+	// MediaKindVPAIDFlash is a Flash VPAID unit.
+	MediaKindVPAIDFlash
+	// MediaKindSIMID is a SIMID interactive creative.
+	MediaKindSIMID
+	// MediaKindMRAID is a MRAID rich media creative.
+	MediaKindMRAID
+)
+
+// String implements the fmt.Stringer interface.
+func (k MediaKind) String() string {
+	switch k {
+	case MediaKindVideo:
+		return "video"
+	case MediaKindVPAIDJS:
+		return "vpaid-js"
+	case MediaKindVPAIDFlash:
+		return "vpaid-flash"
+	case MediaKindSIMID:
+		return "simid"
+	case MediaKindMRAID:
+		return "mraid"
+	}
+	return "unknown"
+}
+
+// Interactive returns true if the kind requires the player to execute code.
+func (k MediaKind) Interactive() bool {
+	return k != MediaKindVideo
+}
+
+// classifyMedia returns the kind of a file given the API framework it declares
+// and its MIME type.
+func classifyMedia(apiFramework, mimeType string) MediaKind {
+	api := strings.ToLower(strings.TrimSpace(apiFramework))
+	switch {
+	case api == "simid":
+		return MediaKindSIMID
+	case strings.HasPrefix(api, "mraid"):
+		return MediaKindMRAID
+	}
+	// VPAID units, or units with no framework, are told apart by their type
+	mime := strings.ToLower(strings.TrimSpace(mimeType))
+	switch {
+	case strings.Contains(mime, "javascript"):
+		return MediaKindVPAIDJS
+	case strings.Contains(mime, "shockwave-flash"):
+		return MediaKindVPAIDFlash
+	case mime == "" && api == "vpaid":
+		return MediaKindVPAIDJS
+	}
+	return MediaKindVideo
+}
+
+// Kind returns the kind of the media file based on its API framework and MIME
+// type.
+func (m MediaFile) Kind() MediaKind {
+	return classifyMedia(m.APIFramework, m.Type)
+}
+
+// Kind returns the kind of the interactive creative file. Interactive creative
+// files with no API framework are considered SIMID.
+func (f InteractiveCreativeFile) Kind() MediaKind {
+	if f.APIFramework == "" {
+		return MediaKindSIMID
+	}
+	return classifyMedia(f.APIFramework, f.Type)
+}
+
+// MediaFileKind returns the kind of a media file of the creative, using the
+// creative API framework when the media file doesn't define one.
+func (c *Creative) MediaFileKind(m MediaFile) MediaKind {
+	if m.APIFramework == "" {
+		return classifyMedia(c.APIFramework, m.Type)
+	}
+	return m.Kind()
+}
+
+// Kind returns the kind of the creative. A linear creative shipping an
+// interactive creative file is SIMID, otherwise the creative takes the kind of
+// its first interactive media file, if any.
+func (c *Creative) Kind() MediaKind {
+	if c.Linear == nil {
+		return classifyMedia(c.APIFramework, "")
+	}
+	for _, f := range c.Linear.InteractiveCreativeFiles {
+		if k := f.Kind(); k.Interactive() {
+			return k
+		}
+	}
+	for _, m := range c.Linear.MediaFiles {
+		if k := c.MediaFileKind(m); k.Interactive() {
+			return k
+		}
+	}
+	return classifyMedia(c.APIFramework, "")
+}
+
+// StripInteractive removes the interactive media files (VPAID, SIMID, MRAID)
+// from the linear creatives of v, keeping plain video files as a fallback.
+// Linear creatives left without any media file are removed.
+//
+// An *Error with code ErrorCodeMediaFileNotSupported (403) is returned only if
+// v had linear creatives but none of them, in any ad, has a playable media
+// file left, and no ad has a non-linear creative with a static or HTML
+// resource. Use StripInteractiveAd on each ad to get the error of each
+// stripped ad, e.g. to fire its error tracking URLs. A nil v is ignored.
+func StripInteractive(v *VAST) error {
+	if v == nil {
+		return nil
+	}
+	failed, playable := false, false
+	for i := range v.Ads {
+		if err := StripInteractiveAd(&v.Ads[i]); err != nil {
+			failed = true
+			continue
+		}
+		if v.Ads[i].InLine == nil {
+			continue
+		}
+		for j := range v.Ads[i].InLine.Creatives {
+			if c := &v.Ads[i].InLine.Creatives[j]; c.Linear != nil || c.playableNonLinear() {
+				playable = true
+			}
+		}
+	}
+	if failed && !playable {
+		return &Error{Code: ErrorCodeMediaFileNotSupported, Message: "no playable media file left after removing interactive media"}
+	}
+	return nil
+}
+
+// StripInteractiveAd removes the interactive media files from the linear
+// creatives of an inline ad, as StripInteractive does. An *Error with code
+// ErrorCodeMediaFileNotSupported (403) is returned if the ad had linear
+// creatives but none of them has a playable media file left, unless it has a
+// non-linear creative with a static or HTML resource. A nil ad or a wrapper
+// ad is ignored.
+func StripInteractiveAd(ad *Ad) error {
+	if ad == nil || ad.InLine == nil {
+		return nil
+	}
+	inline := ad.InLine
+	hadLinear, playable := false, false
+	creatives := inline.Creatives[:0]
+	for _, c := range inline.Creatives {
+		if c.Linear == nil {
+			if c.playableNonLinear() {
+				playable = true
+			}
+			creatives = append(creatives, c)
+			continue
+		}
+		hadLinear = true
+		var files []MediaFile
+		for _, m := range c.Linear.MediaFiles {
+			if !c.MediaFileKind(m).Interactive() {
+				files = append(files, m)
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		c.Linear.MediaFiles = files
+		c.Linear.InteractiveCreativeFiles = nil
+		if classifyMedia(c.APIFramework, "").Interactive() {
+			c.APIFramework = ""
+		}
+		playable = true
+		creatives = append(creatives, c)
+	}
+	inline.Creatives = creatives
+	if hadLinear && !playable {
+		return &Error{Code: ErrorCodeMediaFileNotSupported, Message: "no playable media file left after removing interactive media"}
+	}
+	return nil
+}
+
+// playableNonLinear returns true if the creative has a non-linear ad rendered
+// from a static or HTML resource, which requires no code from the player.
+func (c *Creative) playableNonLinear() bool {
+	if c.NonLinearAds == nil {
+		return false
+	}
+	for _, nl := range c.NonLinearAds.NonLinears {
+		switch {
+		case nl.StaticResource != nil && strings.TrimSpace(nl.StaticResource.URI) != "":
+			if !classifyMedia(nl.APIFramework, nl.StaticResource.CreativeType).Interactive() {
+				return true
+			}
+		case nl.HTMLResource != nil && strings.TrimSpace(nl.HTMLResource.HTML) != "":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaKind(t *testing.T) {
+	cases := []struct {
+		creativeAPI string
+		m           MediaFile
+		want        MediaKind
+	}{
+		{"", MediaFile{Type: "video/mp4"}, MediaKindVideo},
+		{"", MediaFile{Type: "application/javascript", APIFramework: "VPAID"}, MediaKindVPAIDJS},
+		{"", MediaFile{Type: "application/javascript"}, MediaKindVPAIDJS},
+		{"", MediaFile{Type: "application/x-shockwave-flash", APIFramework: "VPAID"}, MediaKindVPAIDFlash},
+		{"", MediaFile{Type: "text/html", APIFramework: "SIMID"}, MediaKindSIMID},
+		{"", MediaFile{Type: "text/html", APIFramework: "MRAID-2"}, MediaKindMRAID},
+		{"VPAID", MediaFile{Type: "application/javascript"}, MediaKindVPAIDJS},
+		{"VPAID", MediaFile{Type: "video/mp4"}, MediaKindVideo},
+		{"MRAID", MediaFile{Type: "text/html"}, MediaKindMRAID},
+	}
+	for _, tc := range cases {
+		c := Creative{APIFramework: tc.creativeAPI}
+		assert.Equal(t, tc.want, c.MediaFileKind(tc.m), "%s %+v", tc.creativeAPI, tc.m)
+	}
+	assert.Equal(t, "vpaid-js", MediaKindVPAIDJS.String())
+	assert.False(t, MediaKindVideo.Interactive())
+	assert.True(t, MediaKindSIMID.Interactive())
+}
+
+func TestCreativeKind(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast4_simid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := v.Ads[0].InLine.Creatives[0]
+	if assert.Len(t, c.Linear.InteractiveCreativeFiles, 1) {
+		f := c.Linear.InteractiveCreativeFiles[0]
+		assert.Equal(t, "text/html", f.Type)
+		assert.Equal(t, "SIMID", f.APIFramework)
+		assert.True(t, f.VariableDuration)
+		assert.Equal(t, "https://example.com/simid.html", f.URI)
+	}
+	assert.Equal(t, MediaKindSIMID, c.Kind())
+
+	v, _, _, err = loadFixture("testdata/spotx_vpaid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, MediaKindVPAIDJS, v.Ads[0].InLine.Creatives[0].Kind())
+	assert.Equal(t, MediaKindMRAID, (&Creative{APIFramework: "mraid"}).Kind())
+}
+
+func TestStripInteractive(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast4_simid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, StripInteractive(v))
+	c := v.Ads[0].InLine.Creatives[0]
+	if assert.Len(t, c.Linear.MediaFiles, 1) {
+		assert.Equal(t, "video/mp4", c.Linear.MediaFiles[0].Type)
+	}
+	assert.Empty(t, c.Linear.InteractiveCreativeFiles)
+	assert.Equal(t, MediaKindVideo, c.Kind())
+}
+
+func TestStripInteractiveNoFallback(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/spotx_vpaid.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = StripInteractive(v)
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeMediaFileNotSupported, err.(*Error).Code)
+	}
+	// only the companion creative is left
+	if assert.Len(t, v.Ads[0].InLine.Creatives, 1) {
+		assert.Nil(t, v.Ads[0].InLine.Creatives[0].Linear)
+		assert.NotNil(t, v.Ads[0].InLine.Creatives[0].CompanionAds)
+	}
+}
+
+func TestStripInteractiveAd(t *testing.T) {
+	vpaid := Creative{Linear: &Linear{MediaFiles: []MediaFile{{Type: "application/javascript", APIFramework: "VPAID"}}}}
+	video := Creative{Linear: &Linear{MediaFiles: []MediaFile{{Type: "video/mp4"}}}}
+	v := &VAST{Ads: []Ad{
+		{ID: "interactive", InLine: &InLine{Creatives: []Creative{vpaid}}},
+		{ID: "video", InLine: &InLine{Creatives: []Creative{video}}},
+		{ID: "empty", InLine: &InLine{}},
+		{ID: "wrapper", Wrapper: &Wrapper{}},
+	}}
+	// the whole document is still playable, the stripped ad must be told
+	// apart with StripInteractiveAd
+	assert.NoError(t, StripInteractive(v.Clone()))
+	err := StripInteractiveAd(&v.Ads[0])
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeMediaFileNotSupported, err.(*Error).Code)
+	}
+	assert.Empty(t, v.Ads[0].InLine.Creatives)
+	assert.NoError(t, StripInteractiveAd(&v.Ads[1]))
+	assert.NoError(t, StripInteractiveAd(&v.Ads[2]))
+	assert.NoError(t, StripInteractiveAd(&v.Ads[3]))
+	assert.NoError(t, StripInteractiveAd(nil))
+	assert.NoError(t, StripInteractive(nil))
+
+	// non-linear creatives with a static or HTML resource are playable, not
+	// VPAID ones
+	static := Creative{NonLinearAds: &NonLinearAds{NonLinears: []NonLinear{{StaticResource: &StaticResource{CreativeType: "image/png", URI: "http://banner.png"}}}}}
+	html := Creative{NonLinearAds: &NonLinearAds{NonLinears: []NonLinear{{HTMLResource: &HTMLResource{HTML: "<p>ad</p>"}}}}}
+	script := Creative{NonLinearAds: &NonLinearAds{NonLinears: []NonLinear{{APIFramework: "VPAID", StaticResource: &StaticResource{CreativeType: "application/javascript", URI: "http://unit.js"}}}}}
+	for _, nl := range []Creative{static, html} {
+		v := &VAST{Ads: []Ad{{InLine: &InLine{Creatives: []Creative{vpaid, nl}}}}}
+		assert.NoError(t, StripInteractive(v.Clone()))
+		assert.NoError(t, StripInteractiveAd(&v.Ads[0]))
+		assert.Len(t, v.Ads[0].InLine.Creatives, 1)
+	}
+	v = &VAST{Ads: []Ad{{InLine: &InLine{Creatives: []Creative{vpaid, script}}}}}
+	err = StripInteractive(v)
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeMediaFileNotSupported, err.(*Error).Code)
+	}
+}
@@ -0,0 +1,39 @@
+package vast
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Macros maps VAST macro names, without their square brackets, to the values
+// they should be replaced with (e.g. "ERRORCODE" → "303").
+type Macros map[string]string
+
+// Expand replaces the [NAME] macros of u defined in m by their URL escaped
+// values. Macros not defined in m are left untouched.
+func (m Macros) Expand(u string) string {
+	if len(m) == 0 || strings.IndexByte(u, '[') == -1 {
+		return u
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(u, '[')
+		if i == -1 {
+			break
+		}
+		j := strings.IndexByte(u[i:], ']')
+		if j == -1 {
+			break
+		}
+		j += i
+		b.WriteString(u[:i])
+		if v, ok := m[u[i+1:j]]; ok {
+			b.WriteString(url.QueryEscape(v))
+		} else {
+			b.WriteString(u[i : j+1])
+		}
+		u = u[j+1:]
+	}
+	b.WriteString(u)
+	return b.String()
+}
@@ -0,0 +1,15 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMacrosExpand(t *testing.T) {
+	m := Macros{"ERRORCODE": "303", "ASSETURI": "http://cdn/ad.mp4?a=1"}
+	assert.Equal(t, "http://err?c=303", m.Expand("http://err?c=[ERRORCODE]"))
+	assert.Equal(t, "http://t?u=http%3A%2F%2Fcdn%2Fad.mp4%3Fa%3D1&c=303&p=[CONTENTPLAYHEAD]", m.Expand("http://t?u=[ASSETURI]&c=[ERRORCODE]&p=[CONTENTPLAYHEAD]"))
+	assert.Equal(t, "http://t?[ERRORCODE", m.Expand("http://t?[ERRORCODE"))
+	assert.Equal(t, "http://t?c=[ERRORCODE]", Macros(nil).Expand("http://t?c=[ERRORCODE]"))
+}
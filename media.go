@@ -0,0 +1,75 @@
+package vast
+
+import "strings"
+
+// MediaSelector describes the media files a player is able to play, and is
+// used to pick the best media file of a linear creative.
+type MediaSelector struct {
+	// Types lists the accepted MIME types. Any type is accepted if empty.
+	Types []string
+	// Delivery is the required delivery method ("streaming" or "progressive").
+	// Any delivery method is accepted if empty.
+	Delivery string
+	// MaxBitrate is the maximum bitrate in Kbps. Not limited if zero.
+	MaxBitrate int
+	// Audio selects audio files only, for audio players. Otherwise audio files
+	// are only accepted if their type is listed in Types.
+	Audio bool
+}
+
+// Accepts reports whether m matches the selector.
+func (s MediaSelector) Accepts(m MediaFile) bool {
+	if s.Delivery != "" && !strings.EqualFold(strings.TrimSpace(m.Delivery), s.Delivery) {
+		return false
+	}
+	if s.MaxBitrate > 0 && m.bitrate() > s.MaxBitrate {
+		return false
+	}
+	if s.Audio && !m.IsAudio() {
+		return false
+	}
+	if len(s.Types) == 0 {
+		return s.Audio || !m.IsAudio()
+	}
+	t := mimeType(m.Type)
+	for _, typ := range s.Types {
+		if strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the accepted media file with the highest bitrate. The first
+// accepted media file is returned when bitrates are unknown. The returned
+// boolean is false if no media file is accepted.
+func (s MediaSelector) Select(files []MediaFile) (MediaFile, bool) {
+	best, found := MediaFile{}, false
+	for _, m := range files {
+		if !s.Accepts(m) {
+			continue
+		}
+		if !found || m.bitrate() > best.bitrate() {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+// bitrate returns the bitrate of the media file, or the maximum bitrate of
+// an adaptive stream.
+func (m MediaFile) bitrate() int {
+	if m.Bitrate > 0 {
+		return m.Bitrate
+	}
+	return m.MaxBitrate
+}
+
+// mimeType returns t without its parameters, e.g. "video/mp4" for
+// "video/mp4; codecs=avc1".
+func mimeType(t string) string {
+	if i := strings.IndexByte(t, ';'); i != -1 {
+		t = t[:i]
+	}
+	return strings.TrimSpace(t)
+}
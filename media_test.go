@@ -0,0 +1,36 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaSelector(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/liverail-vast2-linear-companion.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	files := v.Ads[0].InLine.Creatives[0].Linear.MediaFiles
+
+	m, ok := MediaSelector{Types: []string{"video/mp4"}}.Select(files)
+	if assert.True(t, ok) {
+		assert.Equal(t, "http://cdn.liverail.com/adasset4/1331/229/331/hi.mp4", m.URI)
+	}
+	m, ok = MediaSelector{Types: []string{"VIDEO/WEBM"}, MaxBitrate: 600}.Select(files)
+	if assert.True(t, ok) {
+		assert.Equal(t, "http://cdn.liverail.com/adasset4/1331/229/331/me.webm", m.URI)
+	}
+	_, ok = MediaSelector{Delivery: "streaming"}.Select(files)
+	assert.False(t, ok)
+
+	assert.True(t, MediaSelector{Types: []string{"video/mp4"}}.Accepts(MediaFile{Type: "video/mp4; codecs=avc1"}))
+	assert.True(t, MediaSelector{}.Accepts(MediaFile{Type: "video/x-flv"}))
+
+	audio := MediaFile{Type: "audio/mpeg"}
+	assert.False(t, MediaSelector{}.Accepts(audio))
+	assert.True(t, MediaSelector{Types: AudioTypes}.Accepts(audio))
+	assert.True(t, MediaSelector{Audio: true}.Accepts(audio))
+	assert.False(t, MediaSelector{Audio: true}.Accepts(MediaFile{Type: "video/mp4"}))
+	assert.False(t, MediaSelector{Audio: true, Types: []string{"audio/ogg"}}.Accepts(audio))
+}
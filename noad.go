@@ -0,0 +1,82 @@
+package vast
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// NoAd returns a "no ad" response of the given version ("3.0" if empty),
+// containing no ad and the given error tracking URLs. The URLs are kept as
+// is, so their macros (e.g. [ERRORCODE]) are left for the player to expand.
+func NoAd(version string, errorURLs ...string) *VAST {
+	if version == "" {
+		version = "3.0"
+	}
+	v := &VAST{Version: version}
+	for _, u := range errorURLs {
+		v.Errors = append(v.Errors, CDATAString{CDATA: u})
+	}
+	return v
+}
+
+// IsNoAd returns true if v is a valid "no ad" response: a VAST document
+// with a version, containing no ad, possibly with error tracking URLs. It
+// returns false for a nil document, as returned when a response fails to
+// parse, and for a document whose root element is neither VAST nor DAAST,
+// such as the HTML error page of a proxy, so empty responses can be told
+// apart from malformed ones.
+func IsNoAd(v *VAST) bool {
+	return v != nil && len(v.Ads) == 0 && strings.TrimSpace(v.Version) != "" && isVASTRoot(v.root)
+}
+
+// isVASTRoot reports whether name is the name of the root element of a VAST
+// or DAAST document. An empty name, as in documents built in code, is
+// accepted.
+func isVASTRoot(name xml.Name) bool {
+	switch name.Local {
+	case "", "VAST", "DAAST":
+		return true
+	}
+	return false
+}
+
+// UnmarshalXML implements xml.Unmarshaler interface, recording the name of
+// the root element.
+func (v *VAST) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type vast VAST
+	if err := dec.DecodeElement((*vast)(v), &start); err != nil {
+		return err
+	}
+	v.root = start.Name
+	return nil
+}
+
+// ErrorURLs returns the error tracking URLs of the document, to be requested
+// when it is a "no ad" response.
+func (v *VAST) ErrorURLs() []string {
+	var urls []string
+	for _, e := range v.Errors {
+		urls = appendURL(urls, e.CDATA)
+	}
+	return urls
+}
+
+// WrapperNoAdURLs returns the error tracking URLs to request when the
+// response res to the wrapper ad contains no ad: the error URLs of the wrapper
+// and those of the response, with the [ERRORCODE] macro set to 303 (no ads
+// VAST response after one or more wrappers).
+func WrapperNoAdURLs(wrapper *Ad, res *VAST) []string {
+	m := Macros{"ERRORCODE": strconv.Itoa(int(ErrorCodeWrapperNoAds))}
+	var urls []string
+	if wrapper != nil {
+		urls = append(urls, wrapper.ErrorURLs()...)
+	}
+	if res != nil {
+		urls = append(urls, res.ErrorURLs()...)
+	}
+	for i, u := range urls {
+		urls[i] = m.Expand(u)
+	}
+	return urls
+}
@@ -0,0 +1,78 @@
+package vast
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoAd(t *testing.T) {
+	v := NoAd("", "http://adserver/error?code=[ERRORCODE]")
+	b, err := xml.Marshal(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `<VAST version="3.0"><Error><![CDATA[http://adserver/error?code=[ERRORCODE]]]></Error></VAST>`, string(b))
+
+	var v2 VAST
+	if assert.NoError(t, xml.Unmarshal(b, &v2)) {
+		assert.True(t, IsNoAd(&v2))
+		assert.Equal(t, []string{"http://adserver/error?code=[ERRORCODE]"}, v2.ErrorURLs())
+	}
+	assert.Equal(t, "4.0", NoAd("4.0").Version)
+}
+
+func TestIsNoAd(t *testing.T) {
+	assert.False(t, IsNoAd(nil))
+
+	var v VAST
+	assert.NoError(t, xml.Unmarshal([]byte(`<VAST version="2.0"/>`), &v))
+	assert.True(t, IsNoAd(&v))
+
+	assert.True(t, IsNoAd(NoAd("")))
+	for _, doc := range []string{`<DAAST version="1.0"/>`, `<v:VAST xmlns:v="urn:vast" version="4.0"/>`} {
+		v = VAST{}
+		if assert.NoError(t, xml.Unmarshal([]byte(doc), &v)) {
+			assert.True(t, IsNoAd(&v), doc)
+		}
+	}
+	// proxy error pages and documents with no version are not no ad responses
+	for _, doc := range []string{`<html><body>502 Bad Gateway</body></html>`, `<VAST/>`} {
+		v = VAST{}
+		if assert.NoError(t, xml.Unmarshal([]byte(doc), &v)) {
+			assert.False(t, IsNoAd(&v), doc)
+		}
+		got, err := DecodeBytes([]byte(doc), DecodeOptions{})
+		if assert.NoError(t, err) {
+			assert.False(t, IsNoAd(got), doc)
+		}
+	}
+
+	// the root element is recorded, not encoded nor compared
+	for _, doc := range []string{`<DAAST version="1.0"/>`, `<v:VAST xmlns:v="urn:vast" version="1.0"/>`} {
+		v = VAST{}
+		if assert.NoError(t, xml.Unmarshal([]byte(doc), &v)) {
+			b, err := xml.Marshal(v)
+			assert.NoError(t, err)
+			assert.Equal(t, `<VAST version="1.0"></VAST>`, string(b), doc)
+			assert.True(t, Equal(&VAST{Version: "1.0"}, &v), doc)
+			assert.Empty(t, Diff(&VAST{Version: "1.0"}, &v), doc)
+		}
+	}
+
+	ad, _, _, err := loadFixture("testdata/vast_inline_linear.xml")
+	if assert.NoError(t, err) {
+		assert.False(t, IsNoAd(ad))
+	}
+}
+
+func TestWrapperNoAdURLs(t *testing.T) {
+	wrapper := &Ad{Wrapper: &Wrapper{Errors: []CDATAString{{CDATA: " http://wrapper/error?c=[ERRORCODE] "}}}}
+	res := NoAd("3.0", "http://partner/error?c=[ERRORCODE]&t=[TIMESTAMP]")
+	assert.Equal(t, []string{
+		"http://wrapper/error?c=303",
+		"http://partner/error?c=303&t=[TIMESTAMP]",
+	}, WrapperNoAdURLs(wrapper, res))
+	assert.Nil(t, WrapperNoAdURLs(nil, nil))
+}
@@ -0,0 +1,107 @@
+package vast
+
+import "time"
+
+// Observer receives the events of the decoding of documents, of the
+// resolution of ads and of the firing of beacons, so metrics can be collected without depending on any metrics
+// library. An Observer must be safe for concurrent use and should not block.
+//
+// Embed NopObserver to implement only a subset of the events.
+type Observer interface {
+	// OnFetch is called after each ad tag request, whether it succeeded or
+	// not.
+	OnFetch(FetchEvent)
+	// OnDecode is called with the outcome of each document decoded by Decode
+	// or DecodeBytes with the Observer in their DecodeOptions.
+	OnDecode(DecodeEvent)
+	// OnDecodeError is called when an ad tag response is not a valid VAST
+	// document.
+	OnDecodeError(DecodeErrorEvent)
+	// OnHop is called for each wrapper hop followed, once its outcome is
+	// known.
+	OnHop(Hop)
+	// OnResolve is called with each resolved ad, successful or not.
+	OnResolve(ResolvedAd)
+	// OnBeacon is called with the outcome of each beacon fired.
+	OnBeacon(BeaconEvent)
+}
+
+// FetchEvent describes an ad tag request.
+type FetchEvent struct {
+	// URL is the ad tag requested.
+	URL string
+	// Status is the HTTP status of the response, 0 if no response was
+	// received or if it was served from the cache.
+	Status int
+	// Bytes is the size of the response body.
+	Bytes int
+	// Latency is the time spent fetching and decoding the response.
+	Latency time.Duration
+	// Cached is true if the response was served from the cache or shared with
+	// a concurrent request.
+	Cached bool
+	// Err is the error of the request, nil on success.
+	Err error
+}
+
+// DecodeEvent describes the decoding of a document.
+type DecodeEvent struct {
+	// Bytes is the size of the document, or of the part read if it exceeds
+	// the size limit.
+	Bytes int
+	// Duration is the time spent reading and decoding the document.
+	Duration time.Duration
+	// Limit is true if the document exceeded one of the decode limits.
+	Limit bool
+	// Err is the decoding error, nil on success.
+	Err error
+}
+
+// DecodeErrorEvent describes an ad tag response which could not be decoded.
+type DecodeErrorEvent struct {
+	// URL is the ad tag requested.
+	URL string
+	// Bytes is the size of the response body.
+	Bytes int
+	// Err is the decoding error.
+	Err error
+}
+
+// BeaconEvent describes a fired beacon.
+type BeaconEvent struct {
+	// URL is the expanded URL requested.
+	URL string
+	// Status is the HTTP status of the last attempt, 0 if no response was
+	// received.
+	Status int
+	// Attempts is the number of requests made.
+	Attempts int
+	// Duplicate is true if the beacon was not fired because the same URL was
+	// fired recently.
+	Duplicate bool
+	// Duration is the time spent firing the beacon, retries included.
+	Duration time.Duration
+	// Err is the error of the last attempt, nil on success.
+	Err error
+}
+
+// NopObserver is an Observer ignoring all events.
+type NopObserver struct{}
+
+// OnFetch implements the Observer interface.
+func (NopObserver) OnFetch(FetchEvent) {}
+
+// OnDecode implements the Observer interface.
+func (NopObserver) OnDecode(DecodeEvent) {}
+
+// OnDecodeError implements the Observer interface.
+func (NopObserver) OnDecodeError(DecodeErrorEvent) {}
+
+// OnHop implements the Observer interface.
+func (NopObserver) OnHop(Hop) {}
+
+// OnResolve implements the Observer interface.
+func (NopObserver) OnResolve(ResolvedAd) {}
+
+// OnBeacon implements the Observer interface.
+func (NopObserver) OnBeacon(BeaconEvent) {}
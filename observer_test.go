@@ -0,0 +1,161 @@
+package vast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// metricsObserver is an example adapter of Observer to a metrics library,
+// here a minimal registry of counters and histograms identified by name and
+// labels.
+type metricsObserver struct {
+	NopObserver
+	mu         sync.Mutex
+	counters   map[string]int
+	histograms map[string][]float64
+}
+
+func newMetricsObserver() *metricsObserver {
+	return &metricsObserver{counters: map[string]int{}, histograms: map[string][]float64{}}
+}
+
+func (m *metricsObserver) inc(name string) {
+	m.mu.Lock()
+	m.counters[name]++
+	m.mu.Unlock()
+}
+
+func (m *metricsObserver) observe(name string, v float64) {
+	m.mu.Lock()
+	m.histograms[name] = append(m.histograms[name], v)
+	m.mu.Unlock()
+}
+
+func (m *metricsObserver) OnFetch(e FetchEvent) {
+	m.inc(fmt.Sprintf("vast_fetch_total{status=%d,cached=%t}", e.Status, e.Cached))
+}
+
+func (m *metricsObserver) OnDecode(e DecodeEvent) {
+	m.inc(fmt.Sprintf("vast_decode_total{success=%t,limit=%t}", e.Err == nil, e.Limit))
+}
+
+func (m *metricsObserver) OnDecodeError(e DecodeErrorEvent) {
+	m.inc("vast_decode_errors_total")
+}
+
+func (m *metricsObserver) OnHop(h Hop) {
+	m.observe(fmt.Sprintf("vast_hop_latency_seconds{ad_system=%q}", h.AdSystem), h.Latency.Seconds())
+}
+
+func (m *metricsObserver) OnResolve(ra ResolvedAd) {
+	code := 0
+	if e, ok := ra.Err.(*Error); ok {
+		code = int(e.Code)
+	}
+	m.inc(fmt.Sprintf("vast_resolve_total{error_code=%d}", code))
+	m.observe("vast_wrapper_depth", float64(len(ra.Trace)))
+}
+
+func (m *metricsObserver) OnBeacon(e BeaconEvent) {
+	m.inc(fmt.Sprintf("vast_beacon_total{success=%t}", e.Err == nil))
+}
+
+var _ Observer = (*metricsObserver)(nil)
+
+func TestObserver(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/w", wrapperDoc(s.URL+"/inline"), nil)
+	s.handle("/inline", inlineDoc, nil)
+	s.handle("/bad", `<VAST><Ad>`, nil)
+
+	m := newMetricsObserver()
+	r := &Resolver{Observer: m, DecodeOptions: DecodeOptions{Observer: m}}
+	_, err := r.Resolve(context.Background(), &VAST{Ads: []Ad{
+		{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/w"}}},
+		{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/bad"}}},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"vast_fetch_total{status=200,cached=false}":    3,
+		"vast_decode_errors_total":                     1,
+		"vast_decode_total{success=true,limit=false}":  2,
+		"vast_decode_total{success=false,limit=false}": 1,
+		"vast_resolve_total{error_code=0}":             1,
+		"vast_resolve_total{error_code=100}":           1,
+	}, m.counters)
+	assert.Len(t, m.histograms[`vast_hop_latency_seconds{ad_system="w"}`], 1)
+	assert.Len(t, m.histograms[`vast_hop_latency_seconds{ad_system="i"}`], 1)
+	assert.ElementsMatch(t, []float64{1, 2}, m.histograms["vast_wrapper_depth"])
+}
+
+func TestDecodeObserver(t *testing.T) {
+	m := newMetricsObserver()
+	opts := DecodeOptions{Observer: m}
+	deep := `<VAST><Ad><InLine><AdTitle>abcdef</AdTitle></InLine></Ad></VAST>`
+	_, err := Decode(strings.NewReader(inlineDoc), opts)
+	assert.NoError(t, err)
+	_, err = DecodeBytes([]byte(inlineDoc), opts)
+	assert.NoError(t, err)
+	_, err = Decode(strings.NewReader(`<VAST><Ad>`), opts)
+	assert.Error(t, err)
+	_, err = DecodeBytes([]byte(`<VAST><Ad>`), opts)
+	assert.Error(t, err)
+	_, err = Decode(strings.NewReader(deep), DecodeOptions{Observer: m, MaxDepth: 3})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	_, err = DecodeBytes([]byte(deep), DecodeOptions{Observer: m, MaxDepth: 3})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	assert.Equal(t, map[string]int{
+		"vast_decode_total{success=true,limit=false}":  2,
+		"vast_decode_total{success=false,limit=false}": 2,
+		"vast_decode_total{success=false,limit=true}":  2,
+	}, m.counters)
+
+	o := &decodeRecorder{}
+	_, err = Decode(strings.NewReader(inlineDoc), DecodeOptions{Observer: o, MaxSize: 10})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	_, err = DecodeBytes([]byte(inlineDoc), DecodeOptions{Observer: o, MaxSize: 10})
+	assert.True(t, errors.Is(err, ErrDecodeLimit))
+	if assert.Len(t, o.events, 2) {
+		assert.Equal(t, 11, o.events[0].Bytes)
+		assert.Equal(t, len(inlineDoc), o.events[1].Bytes)
+		for _, e := range o.events {
+			assert.True(t, e.Limit)
+			assert.Equal(t, err.Error(), e.Err.Error())
+		}
+	}
+
+	// a cached response is reported once per decoding, not twice by the
+	// fetch leader
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/inline", inlineDoc, http.Header{"Cache-Control": {"max-age=60"}})
+	o.events = nil
+	r := &Resolver{Cache: NewCache(NewMemoryCache(10, 0)), DecodeOptions: DecodeOptions{Observer: o}}
+	for i := 0; i < 2; i++ {
+		_, err = r.Fetch(context.Background(), s.URL+"/inline")
+		assert.NoError(t, err)
+	}
+	assert.Len(t, o.events, 2)
+	assert.Equal(t, 1, s.hitCount("/inline"))
+}
+
+// decodeRecorder is an Observer recording the decoding events.
+type decodeRecorder struct {
+	NopObserver
+	mu     sync.Mutex
+	events []DecodeEvent
+}
+
+func (d *decodeRecorder) OnDecode(e DecodeEvent) {
+	d.mu.Lock()
+	d.events = append(d.events, e)
+	d.mu.Unlock()
+}
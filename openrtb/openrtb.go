@@ -0,0 +1,223 @@
+// Package openrtb integrates VAST documents with OpenRTB 2.x bid responses.
+//
+// It extracts the VAST document of a bid, either inlined in the bid markup
+// (adm) or fetched from the win notice URL (nurl), expands the OpenRTB
+// auction macros (${AUCTION_PRICE}, ${AUCTION_ID}...) in every URL of the
+// document, and injects the billing notice and the clearing price before
+// rendering the updated markup.
+package openrtb
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/vast"
+)
+
+// ErrNoMarkup is returned when a bid has neither markup nor win notice URL.
+var ErrNoMarkup = errors.New("openrtb: bid has no adm nor nurl")
+
+// Bid is the subset of an OpenRTB 2.x bid object relevant to VAST.
+type Bid struct {
+	ID    string  `json:"id"`
+	ImpID string  `json:"impid"`
+	Price float64 `json:"price"`
+	AdID  string  `json:"adid,omitempty"`
+	// NURL is the win notice URL. When AdM is empty, the markup is returned
+	// by the win notice.
+	NURL string `json:"nurl,omitempty"`
+	// BURL is the billing notice URL, fired when the impression is billable.
+	BURL string `json:"burl,omitempty"`
+	// AdM is the markup, a VAST XML document for video bids.
+	AdM  string `json:"adm,omitempty"`
+	CrID string `json:"crid,omitempty"`
+}
+
+// Auction holds the values substituted to the OpenRTB auction macros.
+type Auction struct {
+	// ID of the bid request (${AUCTION_ID}).
+	ID string
+	// SeatID of the winning bid (${AUCTION_SEAT_ID}).
+	SeatID string
+	// Price is the clearing price (${AUCTION_PRICE}).
+	Price float64
+	// Currency of the clearing price (${AUCTION_CURRENCY}), "USD" if empty.
+	Currency string
+	// Bid is the winning bid, providing ${AUCTION_BID_ID}, ${AUCTION_IMP_ID}
+	// and ${AUCTION_AD_ID}.
+	Bid *Bid
+}
+
+// Macros returns the auction macros by name, without the ${} delimiters.
+func (a Auction) Macros() map[string]string {
+	m := map[string]string{
+		"AUCTION_ID":       a.ID,
+		"AUCTION_SEAT_ID":  a.SeatID,
+		"AUCTION_PRICE":    strconv.FormatFloat(a.Price, 'f', -1, 64),
+		"AUCTION_CURRENCY": a.currency(),
+	}
+	if a.Bid != nil {
+		m["AUCTION_BID_ID"] = a.Bid.ID
+		m["AUCTION_IMP_ID"] = a.Bid.ImpID
+		m["AUCTION_AD_ID"] = a.Bid.AdID
+	}
+	return m
+}
+
+func (a Auction) currency() string {
+	if a.Currency == "" {
+		return "USD"
+	}
+	return a.Currency
+}
+
+// Expand replaces the ${NAME} auction macros in s by their URL escaped
+// values. Unknown macros are left untouched.
+func (a Auction) Expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	m := a.Macros()
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i == -1 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j == -1 {
+			break
+		}
+		j += i
+		b.WriteString(s[:i])
+		if v, ok := m[s[i+2:j]]; ok {
+			b.WriteString(url.QueryEscape(v))
+		} else {
+			b.WriteString(s[i : j+1])
+		}
+		s = s[j+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// Extract returns the VAST document of the bid. When the bid has no markup,
+// the win notice URL is requested, with its macros expanded, using client
+// (http.DefaultClient if nil) and its response body, limited to
+// vast.DefaultMaxDocumentSize bytes, is used as markup.
+func Extract(ctx context.Context, client *http.Client, bid *Bid, a Auction) (*vast.VAST, error) {
+	adm := strings.TrimSpace(bid.AdM)
+	if adm == "" {
+		if bid.NURL == "" {
+			return nil, ErrNoMarkup
+		}
+		b, err := fetch(ctx, client, a.Expand(bid.NURL))
+		if err != nil {
+			return nil, err
+		}
+		adm = string(b)
+	}
+	v, err := vast.DecodeBytes([]byte(adm), vast.DecodeOptions{})
+	if err != nil {
+		return nil, &vast.Error{Code: vast.ErrorCodeXMLParsing, Message: err.Error()}
+	}
+	return v, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openrtb: nurl returned status %d", res.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, vast.DefaultMaxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > vast.DefaultMaxDocumentSize {
+		return nil, fmt.Errorf("openrtb: nurl response larger than %d bytes", vast.DefaultMaxDocumentSize)
+	}
+	return b, nil
+}
+
+// ExpandMacros expands the auction macros in every URL of v.
+func ExpandMacros(v *vast.VAST, a Auction) {
+	v.RewriteURLs(a.Expand)
+}
+
+// Inject adds the billing notice URL of the bid, if any, as an impression of
+// every ad of v and sets the clearing price of the auction as the CPM pricing
+// of the ads. The pricing sent by the buyer is kept if it has a value, whatever
+// its model.
+func Inject(v *vast.VAST, bid *Bid, a Auction) {
+	p := vast.Pricing{
+		Model:    string(vast.PricingModelCPM),
+		Currency: a.currency(),
+		Value:    strconv.FormatFloat(a.Price, 'f', -1, 64),
+	}
+	burl := ""
+	if bid != nil && bid.BURL != "" {
+		burl = a.Expand(bid.BURL)
+	}
+	for i := range v.Ads {
+		ad := &v.Ads[i]
+		switch {
+		case ad.InLine != nil:
+			if burl != "" {
+				ad.InLine.Impressions = append(ad.InLine.Impressions, vast.Impression{URI: burl})
+			}
+			setPricing(&ad.InLine.Pricing, p)
+		case ad.Wrapper != nil:
+			if burl != "" {
+				ad.Wrapper.Impressions = append(ad.Wrapper.Impressions, vast.Impression{URI: burl})
+			}
+			setPricing(&ad.Wrapper.Pricing, p)
+		}
+	}
+}
+
+// setPricing sets *dst to a copy of p unless it already has a value.
+func setPricing(dst **vast.Pricing, p vast.Pricing) {
+	if *dst != nil && strings.TrimSpace((*dst).Value) != "" {
+		return
+	}
+	*dst = &p
+}
+
+// Process extracts the VAST document of the bid, expands the auction macros
+// in all its URLs, injects the billing notice and pricing, and returns the
+// updated markup.
+func Process(ctx context.Context, client *http.Client, bid *Bid, a Auction) (string, error) {
+	if a.Bid == nil {
+		a.Bid = bid
+	}
+	v, err := Extract(ctx, client, bid, a)
+	if err != nil {
+		return "", err
+	}
+	ExpandMacros(v, a)
+	Inject(v, bid, a)
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,96 @@
+package openrtb
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/rs/vast"
+	"github.com/rs/vast/vasttest"
+	"github.com/stretchr/testify/assert"
+)
+
+const adm = `<VAST version="3.0"><Ad id="1"><InLine>` +
+	`<AdSystem>dsp</AdSystem><AdTitle>ad</AdTitle>` +
+	`<Impression><![CDATA[http://dsp/imp?price=${AUCTION_PRICE}&id=${AUCTION_ID}]]></Impression>` +
+	`<Error><![CDATA[http://dsp/err?code=[ERRORCODE]&imp=${AUCTION_IMP_ID}]]></Error>` +
+	`<Creatives><Creative><Linear><Duration>00:00:15</Duration>` +
+	`<TrackingEvents><Tracking event="start"><![CDATA[http://dsp/start?bid=${AUCTION_BID_ID}&x=${UNKNOWN}]]></Tracking></TrackingEvents>` +
+	`<MediaFiles><MediaFile delivery="progressive" type="video/mp4" width="640" height="360"><![CDATA[http://cdn/ad.mp4]]></MediaFile></MediaFiles>` +
+	`</Linear></Creative></Creatives></InLine></Ad></VAST>`
+
+func TestAuctionExpand(t *testing.T) {
+	a := Auction{ID: "req 1", Price: 1.25, Bid: &Bid{ID: "b1", ImpID: "i1"}}
+	assert.Equal(t, "http://x?p=1.25&c=USD&id=req+1&b=b1&i=i1&u=${UNKNOWN}&e=[ERRORCODE]",
+		a.Expand("http://x?p=${AUCTION_PRICE}&c=${AUCTION_CURRENCY}&id=${AUCTION_ID}&b=${AUCTION_BID_ID}&i=${AUCTION_IMP_ID}&u=${UNKNOWN}&e=[ERRORCODE]"))
+	assert.Equal(t, "http://x?p=${AUCTION_PRICE", a.Expand("http://x?p=${AUCTION_PRICE"))
+}
+
+func TestProcessAdM(t *testing.T) {
+	bid := &Bid{ID: "b1", ImpID: "i1", Price: 2, AdM: adm, BURL: "http://ssp/bill?p=${AUCTION_PRICE}"}
+	out, err := Process(context.Background(), nil, bid, Auction{ID: "a1", Price: 1.5, Currency: "EUR"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var v vast.VAST
+	if !assert.NoError(t, xml.Unmarshal([]byte(out), &v)) {
+		return
+	}
+	in := v.Ads[0].InLine
+	assert.Equal(t, []vast.Impression{
+		{URI: "http://dsp/imp?price=1.5&id=a1"},
+		{URI: "http://ssp/bill?p=1.5"},
+	}, in.Impressions)
+	assert.Equal(t, "http://dsp/err?code=[ERRORCODE]&imp=i1", in.Errors[0].CDATA)
+	assert.Equal(t, "http://dsp/start?bid=b1&x=${UNKNOWN}", in.Creatives[0].Linear.TrackingEvents[0].URI)
+	assert.Equal(t, &vast.Pricing{Model: "cpm", Currency: "EUR", Value: "1.5"}, in.Pricing)
+}
+
+func TestProcessNURL(t *testing.T) {
+	s := vasttest.NewServer()
+	defer s.Close()
+	s.HandleRaw("/win", []byte(adm))
+
+	bid := &Bid{ID: "b1", NURL: s.URLFor("/win?price=${AUCTION_PRICE}")}
+	v, err := Extract(context.Background(), nil, bid, Auction{Price: 3})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", v.Ads[0].ID)
+	}
+	assert.True(t, s.Fired("/win?price=3"))
+
+	s.HandleStatus("/nobid", 204)
+	_, err = Extract(context.Background(), nil, &Bid{NURL: s.URLFor("/nobid")}, Auction{})
+	assert.Error(t, err)
+
+	s.HandleRaw("/large", make([]byte, vast.DefaultMaxDocumentSize+1))
+	_, err = Extract(context.Background(), nil, &Bid{NURL: s.URLFor("/large")}, Auction{})
+	assert.EqualError(t, err, fmt.Sprintf("openrtb: nurl response larger than %d bytes", vast.DefaultMaxDocumentSize))
+}
+
+func TestExtractErrors(t *testing.T) {
+	_, err := Extract(context.Background(), nil, &Bid{}, Auction{})
+	assert.Equal(t, ErrNoMarkup, err)
+
+	_, err = Extract(context.Background(), nil, &Bid{AdM: "<VAST><Ad>"}, Auction{})
+	if assert.IsType(t, &vast.Error{}, err) {
+		assert.Equal(t, vast.ErrorCodeXMLParsing, err.(*vast.Error).Code)
+	}
+}
+
+func TestInjectWrapper(t *testing.T) {
+	v := &vast.VAST{Ads: []vast.Ad{{Wrapper: &vast.Wrapper{}}}}
+	Inject(v, &Bid{BURL: "http://bill"}, Auction{Price: 0.5})
+	assert.Equal(t, []vast.Impression{{URI: "http://bill"}}, v.Ads[0].Wrapper.Impressions)
+	assert.Equal(t, &vast.Pricing{Model: "cpm", Currency: "USD", Value: "0.5"}, v.Ads[0].Wrapper.Pricing)
+}
+
+func TestInjectKeepsPricing(t *testing.T) {
+	v := &vast.VAST{Ads: []vast.Ad{
+		{InLine: &vast.InLine{Pricing: &vast.Pricing{Model: "cpc", Currency: "EUR", Value: "0.2"}}},
+		{InLine: &vast.InLine{Pricing: &vast.Pricing{Model: "cpm", Value: " "}}},
+	}}
+	Inject(v, nil, Auction{Price: 1})
+	assert.Equal(t, &vast.Pricing{Model: "cpc", Currency: "EUR", Value: "0.2"}, v.Ads[0].InLine.Pricing)
+	assert.Equal(t, &vast.Pricing{Model: "cpm", Currency: "USD", Value: "1"}, v.Ads[1].InLine.Pricing)
+}
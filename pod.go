@@ -0,0 +1,31 @@
+package vast
+
+import "sort"
+
+// Pod returns the ads of v that are part of an ad pod, that is the ads with a
+// sequence number, sorted by sequence.
+func (v *VAST) Pod() []Ad {
+	var pod []Ad
+	for _, ad := range v.Ads {
+		if ad.Sequence > 0 {
+			pod = append(pod, ad)
+		}
+	}
+	sort.SliceStable(pod, func(i, j int) bool {
+		return pod[i].Sequence < pod[j].Sequence
+	})
+	return pod
+}
+
+// Buffet returns the stand-alone ads of v, that is the ads with no sequence
+// number, in document order. Those ads may be used as fallback when an ad of
+// the pod can't be played.
+func (v *VAST) Buffet() []Ad {
+	var buffet []Ad
+	for _, ad := range v.Ads {
+		if ad.Sequence == 0 {
+			buffet = append(buffet, ad)
+		}
+	}
+	return buffet
+}
@@ -0,0 +1,28 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPod(t *testing.T) {
+	v := &VAST{Ads: []Ad{
+		{ID: "c", Sequence: 3},
+		{ID: "buffet1"},
+		{ID: "a", Sequence: 1},
+		{ID: "b", Sequence: 2},
+		{ID: "buffet2"},
+	}}
+	var ids []string
+	for _, ad := range v.Pod() {
+		ids = append(ids, ad.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+	ids = nil
+	for _, ad := range v.Buffet() {
+		ids = append(ids, ad.ID)
+	}
+	assert.Equal(t, []string{"buffet1", "buffet2"}, ids)
+	assert.Empty(t, (&VAST{}).Pod())
+}
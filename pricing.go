@@ -0,0 +1,174 @@
+package vast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PricingModel is the pricing model of a Pricing element.
+type PricingModel string
+
+// Pricing models defined by the VAST specification.
+const (
+	// Cost per thousand impressions.
+	PricingModelCPM PricingModel = "cpm"
+	// Cost per click.
+	PricingModelCPC PricingModel = "cpc"
+	// Cost per engagement.
+	PricingModelCPE PricingModel = "cpe"
+	// Cost per view.
+	PricingModelCPV PricingModel = "cpv"
+)
+
+// Valid returns true if m is one of the pricing models defined by the
+// specification.
+func (m PricingModel) Valid() bool {
+	switch m {
+	case PricingModelCPM, PricingModelCPC, PricingModelCPE, PricingModelCPV:
+		return true
+	}
+	return false
+}
+
+// PriceDecoder decodes an obfuscated or encrypted price value into its clear
+// text decimal representation. The mechanism is negotiated between
+// publishers and advertisers.
+type PriceDecoder func(p *Pricing) (string, error)
+
+// PricingModel returns the normalized pricing model of p. The model is case
+// insensitive; an error is returned if it is not one of the models defined by
+// the specification.
+func (p *Pricing) PricingModel() (PricingModel, error) {
+	m := PricingModel(strings.ToLower(strings.TrimSpace(p.Model)))
+	if !m.Valid() {
+		return "", fmt.Errorf("vast: invalid pricing model: %q", p.Model)
+	}
+	return m, nil
+}
+
+// CurrencyCode returns the normalized ISO-4217 currency code of p. The code is
+// case insensitive; an error is returned if it is not an active ISO-4217
+// currency code.
+func (p *Pricing) CurrencyCode() (string, error) {
+	c := strings.ToUpper(strings.TrimSpace(p.Currency))
+	if !ValidCurrency(c) {
+		return "", fmt.Errorf("vast: invalid pricing currency: %q", p.Currency)
+	}
+	return c, nil
+}
+
+// Amount returns the price value of p as a number. Surrounding whitespace is
+// ignored and both the dot and the comma are accepted as decimal separator.
+func (p *Pricing) Amount() (float64, error) {
+	return parsePrice(p.Value)
+}
+
+// DecodeAmount is like Amount but passes p to dec to obtain the clear text
+// value first. If dec is nil, it behaves like Amount.
+func (p *Pricing) DecodeAmount(dec PriceDecoder) (float64, error) {
+	if dec == nil {
+		return p.Amount()
+	}
+	v, err := dec(p)
+	if err != nil {
+		return 0, fmt.Errorf("vast: cannot decode price: %v", err)
+	}
+	return parsePrice(v)
+}
+
+// parsePrice parses a decimal price. When both separators are present, the
+// last one is the decimal separator and the other one groups thousands
+// (e.g. "1,234.56" or "1.234,56").
+func parsePrice(s string) (float64, error) {
+	v := strings.TrimSpace(s)
+	dot, comma := strings.LastIndexByte(v, '.'), strings.LastIndexByte(v, ',')
+	switch {
+	case comma > dot:
+		v = strings.Replace(v, ".", "", -1)
+		v = strings.Replace(v, ",", ".", -1)
+	case dot > comma && comma != -1:
+		v = strings.Replace(v, ",", "", -1)
+	}
+	if !isDecimal(v) {
+		return 0, fmt.Errorf("vast: invalid price: %q", s)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("vast: invalid price: %q", s)
+	}
+	return f, nil
+}
+
+// isDecimal reports whether s is a plain decimal number such as "12", "1.5"
+// or ".5", rejecting signs, exponents, hexadecimal and special values such as
+// "NaN" or "Inf" accepted by strconv.ParseFloat.
+func isDecimal(s string) bool {
+	digits, dot := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// Pricing returns the pricing of the ad, or nil if it has none.
+func (ad *Ad) Pricing() *Pricing {
+	switch {
+	case ad.InLine != nil:
+		return ad.InLine.Pricing
+	case ad.Wrapper != nil:
+		return ad.Wrapper.Pricing
+	}
+	return nil
+}
+
+// ChainPricing returns the pricing to consider for a chain of ads, ordered
+// from the outermost wrapper to the inline ad. As only the value offered in
+// the first wrapper need be considered, the first pricing found in the chain
+// is returned, or nil if no ad has one.
+func ChainPricing(chain []Ad) *Pricing {
+	for i := range chain {
+		if p := chain[i].Pricing(); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// ValidCurrency returns true if code is an active ISO-4217 alphabetic
+// currency code. The code is case sensitive.
+func ValidCurrency(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+// currencies lists the active ISO-4217 alphabetic currency codes, as of
+// 2026: withdrawn currencies (e.g. ANG, BGN, CUC, SLL, ZWL) and the test and
+// no currency codes (XTS, XXX) are not accepted. The list is maintained by
+// hand and must be updated when currencies are added or withdrawn.
+var currencies = map[string]struct{}{}
+
+func init() {
+	for _, c := range strings.Fields(`
+		AED AFN ALL AMD AOA ARS AUD AWG AZN BAM BBD BDT BHD BIF BMD BND
+		BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY
+		COP COU CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP
+		GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD
+		IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR
+		LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR
+		MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON
+		RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC
+		SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU
+		UYW UZS VED VES VND VUV WST XAF XAG XAU XBA XBB XBC XBD XCD XCG XDR
+		XOF XPD XPF XPT XSU XUA YER ZAR ZMW ZWG`) {
+		currencies[c] = struct{}{}
+	}
+}
@@ -0,0 +1,102 @@
+package vast
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPricingFixture(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast4_universal_ad_id.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := v.Ads[0].Pricing()
+	if assert.NotNil(t, p) {
+		m, err := p.PricingModel()
+		assert.NoError(t, err)
+		assert.Equal(t, PricingModelCPM, m)
+		c, err := p.CurrencyCode()
+		assert.NoError(t, err)
+		assert.Equal(t, "USD", c)
+		a, err := p.Amount()
+		assert.NoError(t, err)
+		assert.Equal(t, 25.0, a)
+	}
+}
+
+func TestPricingModel(t *testing.T) {
+	m, err := (&Pricing{Model: " CPC "}).PricingModel()
+	assert.NoError(t, err)
+	assert.Equal(t, PricingModelCPC, m)
+	_, err = (&Pricing{Model: "cpa"}).PricingModel()
+	assert.EqualError(t, err, `vast: invalid pricing model: "cpa"`)
+	assert.False(t, PricingModel("").Valid())
+}
+
+func TestPricingCurrency(t *testing.T) {
+	c, err := (&Pricing{Currency: "eur"}).CurrencyCode()
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", c)
+	for _, c := range []string{"", "US", "USDD", "ABC"} {
+		_, err := (&Pricing{Currency: c}).CurrencyCode()
+		assert.Error(t, err, c)
+	}
+	assert.False(t, ValidCurrency("usd"))
+	assert.True(t, ValidCurrency("ZWG"))
+	assert.True(t, ValidCurrency("XCG"))
+	for _, c := range []string{"ZWL", "SLL", "CUC", "XTS", "XXX"} {
+		assert.False(t, ValidCurrency(c), c)
+	}
+}
+
+func TestPricingAmount(t *testing.T) {
+	for s, want := range map[string]float64{
+		"1.5":        1.5,
+		"\n 2,75 \t": 2.75,
+		"1,234.56":   1234.56,
+		"1.234,56":   1234.56,
+		"0":          0,
+		".5":         0.5,
+		"3.":         3,
+	} {
+		a, err := (&Pricing{Value: s}).Amount()
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, want, a, s)
+		}
+	}
+	for _, s := range []string{"", "abc", "1.2.3", "-1", "1,234,567", "NaN", "Inf", "+Inf", "0x1p4", "1e3", "+1", ".", "1_000"} {
+		_, err := (&Pricing{Value: s}).Amount()
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPricingDecodeAmount(t *testing.T) {
+	dec := func(p *Pricing) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(p.Value)
+		return string(b), err
+	}
+	p := &Pricing{Value: base64.StdEncoding.EncodeToString([]byte("3,5"))}
+	a, err := p.DecodeAmount(dec)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, a)
+	_, err = (&Pricing{Value: "!"}).DecodeAmount(dec)
+	assert.Error(t, err)
+	a, err = (&Pricing{Value: "4"}).DecodeAmount(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, a)
+}
+
+func TestChainPricing(t *testing.T) {
+	first := &Pricing{Model: "cpm", Currency: "USD", Value: "2"}
+	chain := []Ad{
+		{Wrapper: &Wrapper{}},
+		{Wrapper: &Wrapper{Pricing: first}},
+		{Wrapper: &Wrapper{Pricing: &Pricing{Value: "3"}}},
+		{InLine: &InLine{Pricing: &Pricing{Value: "1"}}},
+	}
+	assert.Equal(t, first, ChainPricing(chain))
+	assert.Nil(t, ChainPricing([]Ad{{InLine: &InLine{}}}))
+	assert.Nil(t, (&Ad{}).Pricing())
+}
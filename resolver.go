@@ -0,0 +1,515 @@
+package vast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxResponseSize is the size of the largest ad tag response accepted
+// by a Resolver with no MaxResponseSize.
+const DefaultMaxResponseSize = 1 << 20
+
+// DefaultMaxDepth is the maximum number of wrappers followed to reach an
+// inline ad by a Resolver with no MaxDepth, as recommended by the VAST
+// specification.
+const DefaultMaxDepth = 5
+
+// DefaultCacheBusters lists the query parameters commonly used as cache
+// busters, ignored to detect wrapper loops by a Resolver with no
+// CacheBusters.
+var DefaultCacheBusters = []string{"cb", "cachebuster", "cachebusting", "correlator", "ord", "rnd", "random"}
+
+// DefaultMaxConcurrency is the number of ads resolved concurrently by a
+// Resolver with no MaxConcurrency.
+const DefaultMaxConcurrency = 4
+
+// URLFirer fires tracking URLs. It is implemented by *beacon.Dispatcher.
+type URLFirer interface {
+	FireURLs(ctx context.Context, m Macros, urls ...string) error
+}
+
+// Resolver resolves wrapper ads by following their VASTAdTagURI up to the
+// inline ad. A Resolver is safe for concurrent use.
+type Resolver struct {
+	// Client is the HTTP client used to fetch ad tags, http.DefaultClient if
+	// nil.
+	Client *http.Client
+	// Macros are expanded in the ad tag URLs before fetching them.
+	Macros Macros
+	// Cache, if not nil, caches the ad tag responses.
+	Cache *Cache
+	// MaxResponseSize is the size of the largest ad tag response accepted,
+	// DefaultMaxResponseSize if 0.
+	MaxResponseSize int64
+	// MaxDepth is the maximum number of wrappers followed to reach an inline
+	// ad, DefaultMaxDepth if 0.
+	MaxDepth int
+	// CacheBusters lists the query parameters of the ad tags ignored to
+	// detect wrapper loops, DefaultCacheBusters if nil.
+	CacheBusters []string
+	// Timeout, if not 0, is the time budget of the resolution of an ad,
+	// including all its wrappers.
+	Timeout time.Duration
+	// MaxConcurrency is the maximum number of ads of a document resolved
+	// concurrently, DefaultMaxConcurrency if 0.
+	MaxConcurrency int
+	// TotalTimeout, if not 0, is the time budget of the resolution of all the
+	// ads of a document by Resolve and ResolvePod.
+	TotalTimeout time.Duration
+	// Beacons, if not nil, fires the error tracking URLs of the ads which
+	// failed to resolve, with the Macros and the error code expanded.
+	Beacons URLFirer
+	// DecodeOptions defines the limits enforced when decoding the ad tag
+	// responses. Its MaxSize is ignored in favor of MaxResponseSize. Its
+	// Observer, if not nil, is notified once of the decoding of each
+	// response.
+	DecodeOptions DecodeOptions
+	// Observer, if not nil, is notified of the ad tag requests, hops and
+	// resolved ads.
+	Observer Observer
+}
+
+// ResolvedAd is an ad resolved by following its wrappers.
+type ResolvedAd struct {
+	// Chain lists the ads followed, from the ad of the original document to
+	// the inline ad.
+	Chain []Ad
+	// Err is the error which interrupted the resolution, or nil if the inline
+	// ad has been reached. It is an *Error carrying the VAST error code when
+	// the failure is caused by an ad tag.
+	Err error
+	// Trace lists the wrapper hops followed, the last one being the one
+	// which failed if Err is not nil.
+	Trace Trace
+	// ErrorURLs lists the error tracking URLs of the chain, with the
+	// [ERRORCODE] macro expanded, when the resolution failed.
+	ErrorURLs []string
+	// Fallback is true if the ad is a buffet ad replacing an ad of a pod
+	// which failed to resolve.
+	Fallback bool
+}
+
+// InLine returns the inline ad reached, or nil if the resolution failed.
+func (r ResolvedAd) InLine() *Ad {
+	if r.Err != nil || len(r.Chain) == 0 {
+		return nil
+	}
+	ad := &r.Chain[len(r.Chain)-1]
+	if ad.InLine == nil {
+		return nil
+	}
+	return ad
+}
+
+// Resolve resolves all the ads of v concurrently. The resolved ads are
+// returned in document order. An error is returned if no ad could be
+// resolved; it is the error of the first ad.
+func (r *Resolver) Resolve(ctx context.Context, v *VAST) ([]ResolvedAd, error) {
+	if r.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.TotalTimeout)
+		defer cancel()
+	}
+	res := r.resolveAll(ctx, v.Ads)
+	return res, firstError(res)
+}
+
+// ResolvePod resolves the ad pod of v concurrently. The resolved ads are
+// returned in sequence order. Each ad of the pod which fails to resolve is
+// replaced by the next buffet ad of v which resolves, if any; the ads which
+// could not be replaced are returned with their error. If v has no pod,
+// ResolvePod is equivalent to Resolve.
+func (r *Resolver) ResolvePod(ctx context.Context, v *VAST) ([]ResolvedAd, error) {
+	pod := v.Pod()
+	if len(pod) == 0 {
+		return r.Resolve(ctx, v)
+	}
+	if r.TotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.TotalTimeout)
+		defer cancel()
+	}
+	res := r.resolveAll(ctx, pod)
+	var failed []int
+	for i := range res {
+		if res[i].Err != nil {
+			failed = append(failed, i)
+		}
+	}
+	// resolve as many buffet ads as there are failed ads left, in document
+	// order, until all are replaced or the buffet is exhausted
+	buffet := v.Buffet()
+	for len(failed) > 0 && len(buffet) > 0 && ctx.Err() == nil {
+		n := len(failed)
+		if n > len(buffet) {
+			n = len(buffet)
+		}
+		for _, ra := range r.resolveAll(ctx, buffet[:n]) {
+			if ra.Err == nil {
+				ra.Fallback = true
+				res[failed[0]] = ra
+				failed = failed[1:]
+			}
+		}
+		buffet = buffet[n:]
+	}
+	return res, firstError(res)
+}
+
+// resolveAll resolves ads concurrently, at most MaxConcurrency at a time, and
+// returns the resolved ads in the order of ads.
+func (r *Resolver) resolveAll(ctx context.Context, ads []Ad) []ResolvedAd {
+	max := r.MaxConcurrency
+	if max <= 0 {
+		max = DefaultMaxConcurrency
+	}
+	res := make([]ResolvedAd, len(ads))
+	sem := make(chan struct{}, max)
+	var wg sync.WaitGroup
+	for i := range ads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res[i] = r.ResolveAd(ctx, ads[i])
+		}(i)
+	}
+	wg.Wait()
+	return res
+}
+
+// firstError returns the error of the first ad of res if none of them could
+// be resolved.
+func firstError(res []ResolvedAd) error {
+	var err error
+	for _, ra := range res {
+		if ra.Err == nil {
+			return nil
+		}
+		if err == nil {
+			err = ra.Err
+		}
+	}
+	return err
+}
+
+// ResolveAd follows the wrappers of ad up to the inline ad. When an ad tag
+// response contains several ads, its first ad is followed.
+//
+// The resolution fails with a wrapper limit error (302) when more than
+// MaxDepth wrappers are followed or when a wrapper loop is detected, and with
+// a wrapper timeout error (301) when the Timeout budget is exhausted. On
+// failure, the error tracking URLs of the chain are fired through Beacons.
+func (r *Resolver) ResolveAd(ctx context.Context, ad Ad) ResolvedAd {
+	res := r.resolveAd(ctx, ad)
+	if r.Observer != nil {
+		r.Observer.OnResolve(res)
+	}
+	return res
+}
+
+func (r *Resolver) resolveAd(ctx context.Context, ad Ad) ResolvedAd {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	maxDepth := r.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	res := ResolvedAd{Chain: []Ad{ad}}
+	if ad.InLine == nil && ad.Wrapper == nil {
+		r.fail(&res, emptyAdError(), nil)
+		return res
+	}
+	seen := map[string]bool{}
+	for depth := 0; ad.Wrapper != nil; depth++ {
+		uri := strings.TrimSpace(ad.Wrapper.VASTAdTagURI.CDATA)
+		if uri != "" {
+			uri = r.Macros.Expand(uri)
+		}
+		hop := Hop{Depth: depth + 1, URL: uri}
+		var err error
+		switch {
+		case depth == maxDepth:
+			err = &Error{Code: ErrorCodeWrapperLimit, Message: fmt.Sprintf("more than %d wrappers", maxDepth)}
+		case uri == "":
+			err = &Error{Code: ErrorCodeWrapper, Message: "wrapper with no VASTAdTagURI"}
+		case ctx.Err() != nil:
+			err = fetchError(ctx.Err())
+		}
+		if err == nil {
+			key := r.loopKey(&ad, uri)
+			if seen[key] {
+				err = &Error{Code: ErrorCodeWrapperLimit, Message: "wrapper loop detected at " + uri}
+			}
+			seen[key] = true
+		}
+		var v *VAST
+		if err == nil {
+			v, err = r.fetchHop(ctx, &hop)
+			if err == nil && len(v.Ads) == 0 {
+				err = &Error{Code: ErrorCodeWrapperNoAds, Message: "no ad in response of " + uri}
+			}
+		}
+		if err != nil {
+			hop.setError(err)
+			r.addHop(&res, hop)
+			r.fail(&res, err, v)
+			return res
+		}
+		ad = v.Ads[0]
+		hop.setAdSystem(&ad)
+		res.Chain = append(res.Chain, ad)
+		if ad.InLine == nil && ad.Wrapper == nil {
+			err := emptyAdError()
+			hop.setError(err)
+			r.addHop(&res, hop)
+			r.fail(&res, err, nil)
+			return res
+		}
+		r.addHop(&res, hop)
+	}
+	return res
+}
+
+// emptyAdError returns the error of an ad with neither an InLine nor a
+// Wrapper.
+func emptyAdError() error {
+	return &Error{Code: ErrorCodeSchemaValidation, Message: "ad has neither InLine nor Wrapper"}
+}
+
+// addHop appends hop to the trace of res and notifies the observer.
+func (r *Resolver) addHop(res *ResolvedAd, hop Hop) {
+	res.Trace = append(res.Trace, hop)
+	if r.Observer != nil {
+		r.Observer.OnHop(hop)
+	}
+}
+
+// fail sets err as the error of res, collects the error tracking URLs of its
+// chain and of the no ad response noAd, if any, and fires them.
+func (r *Resolver) fail(res *ResolvedAd, err error, noAd *VAST) {
+	res.Err = err
+	code := ErrorCodeUndefined
+	if e, ok := err.(*Error); ok {
+		code = e.Code
+	}
+	m := Macros{"ERRORCODE": strconv.Itoa(int(code))}
+	for i := range res.Chain {
+		for _, u := range res.Chain[i].ErrorURLs() {
+			res.ErrorURLs = append(res.ErrorURLs, m.Expand(u))
+		}
+	}
+	if noAd != nil {
+		for _, u := range noAd.ErrorURLs() {
+			res.ErrorURLs = append(res.ErrorURLs, m.Expand(u))
+		}
+	}
+	if r.Beacons != nil && len(res.ErrorURLs) > 0 {
+		// the resolution context may have expired, errors must be reported
+		// anyway
+		r.Beacons.FireURLs(context.Background(), r.Macros, res.ErrorURLs...)
+	}
+}
+
+// loopKey returns the key identifying the request of the ad tag uri by the
+// wrapper ad: the name of its ad system and the normalized URL, with its query
+// parameters sorted and its cache busters removed. A wrapper of an ad system
+// requesting an ad tag it already requested in the chain, with a new cache
+// buster, is thus detected as a loop. The query is kept as ad servers
+// identify their ad tags with it.
+func (r *Resolver) loopKey(ad *Ad, uri string) string {
+	var system string
+	if sys := ad.Wrapper.AdSystem; sys != nil {
+		system = strings.TrimSpace(sys.Name)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return system + " " + uri
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	busters := r.CacheBusters
+	if busters == nil {
+		busters = DefaultCacheBusters
+	}
+	q := u.Query()
+	for k := range q {
+		for _, b := range busters {
+			if strings.EqualFold(k, b) {
+				q.Del(k)
+				break
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+	return system + " " + u.String()
+}
+
+// Fetch requests the ad tag uri and decodes its response, using the cache if
+// any. A response with no content (204) is decoded as a "no ad" response.
+func (r *Resolver) Fetch(ctx context.Context, uri string) (*VAST, error) {
+	return r.fetchHop(ctx, &Hop{URL: uri})
+}
+
+// fetchHop fetches and decodes the response of hop.URL, recording the
+// details of the request in hop and notifying the observer.
+func (r *Resolver) fetchHop(ctx context.Context, hop *Hop) (v *VAST, err error) {
+	start := time.Now()
+	defer func() {
+		hop.Latency = time.Since(start)
+		if r.Observer == nil {
+			return
+		}
+		r.Observer.OnFetch(FetchEvent{URL: hop.URL, Status: hop.Status, Bytes: hop.Bytes, Latency: hop.Latency, Cached: hop.Cached, Err: err})
+		if e, ok := err.(*Error); ok && e.Code == ErrorCodeXMLParsing {
+			r.Observer.OnDecodeError(DecodeErrorEvent{URL: hop.URL, Bytes: hop.Bytes, Err: err})
+		}
+	}()
+	if r.Cache == nil {
+		b, _, err := r.fetch(ctx, hop)
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeResponse(b, hop)
+	}
+	// the fetch may be shared with concurrent resolutions and outlive this
+	// one, it records its status and decoded response on its own
+	var (
+		fh Hop
+		fv *VAST
+	)
+	b, leader, err := r.Cache.get(ctx, hop.URL, func(ctx context.Context) ([]byte, time.Duration, error) {
+		if r.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+			defer cancel()
+		}
+		fh.URL = hop.URL
+		b, h, err := r.fetch(ctx, &fh)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, err := r.decodeResponse(b, &fh)
+		if err != nil {
+			return nil, 0, err
+		}
+		fv = v
+		return b, r.Cache.ttl(h, v, time.Now()), nil
+	})
+	if leader {
+		hop.Status, hop.Bytes, hop.Warnings = fh.Status, fh.Bytes, fh.Warnings
+	}
+	if err != nil {
+		if _, ok := err.(*Error); !ok {
+			err = fetchError(err)
+		}
+		return nil, err
+	}
+	if leader {
+		return fv, nil
+	}
+	hop.Cached = true
+	hop.Bytes = len(b)
+	return r.decodeResponse(b, hop)
+}
+
+// fetch returns the body and headers of the response to hop.URL, recording
+// its status and size in hop.
+func (r *Resolver) fetch(ctx context.Context, hop *Hop) ([]byte, http.Header, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", hop.URL, nil)
+	if err != nil {
+		return nil, nil, &Error{Code: ErrorCodeWrapper, Message: err.Error()}
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, fetchError(err)
+	}
+	defer res.Body.Close()
+	hop.Status = res.StatusCode
+	switch {
+	case res.StatusCode == http.StatusNoContent:
+		return nil, res.Header, nil
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return nil, nil, &Error{Code: ErrorCodeWrapper, Message: fmt.Sprintf("ad tag returned status %d", res.StatusCode)}
+	}
+	max := r.MaxResponseSize
+	if max == 0 {
+		max = DefaultMaxResponseSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, max+1))
+	if err != nil {
+		return nil, nil, fetchError(err)
+	}
+	hop.Bytes = len(b)
+	if int64(len(b)) > max {
+		return nil, nil, &Error{Code: ErrorCodeWrapper, Message: fmt.Sprintf("ad tag response larger than %d bytes", max)}
+	}
+	return b, res.Header, nil
+}
+
+// fetchError returns the VAST error for a failed request.
+func fetchError(err error) error {
+	var nerr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+		return &Error{Code: ErrorCodeWrapperTimeout, Message: err.Error()}
+	}
+	return &Error{Code: ErrorCodeWrapper, Message: err.Error()}
+}
+
+// decodeResponse decodes an ad tag response, an empty body being a "no ad"
+// response, and records the decoding warnings in hop. The response is
+// decoded with DecodeBytes, its size being bounded by MaxResponseSize.
+func (r *Resolver) decodeResponse(b []byte, hop *Hop) (*VAST, error) {
+	if len(strings.TrimSpace(string(b))) == 0 {
+		hop.warn("empty response")
+		return NoAd(""), nil
+	}
+	opts := r.DecodeOptions
+	opts.MaxSize = int64(len(b))
+	v, err := DecodeBytes(b, opts)
+	if err != nil {
+		return nil, &Error{Code: ErrorCodeXMLParsing, Message: err.Error()}
+	}
+	if !isVASTRoot(v.root) {
+		return nil, &Error{Code: ErrorCodeXMLParsing, Message: fmt.Sprintf("unexpected root element <%s>", v.root.Local)}
+	}
+	if v.Version == "" {
+		if len(v.Ads) == 0 {
+			return nil, &Error{Code: ErrorCodeSchemaValidation, Message: "no ad and no VAST version in response"}
+		}
+		hop.warn("missing VAST version")
+	}
+	for i, ad := range v.Ads {
+		if ad.InLine == nil && ad.Wrapper == nil {
+			hop.warn(fmt.Sprintf("ad %d has neither InLine nor Wrapper", i))
+		}
+	}
+	if len(v.Ads) > 1 {
+		hop.warn(fmt.Sprintf("%d ads in response, following the first one", len(v.Ads)))
+	}
+	return v, nil
+}
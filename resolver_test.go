@@ -0,0 +1,433 @@
+package vast
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testAdServer serves VAST documents by path and counts the requests.
+type testAdServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	docs   map[string]string
+	header map[string]http.Header
+	hits   map[string]int
+}
+
+func newTestAdServer() *testAdServer {
+	s := &testAdServer{docs: map[string]string{}, header: map[string]http.Header{}, hits: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.RequestURI()]++
+		// documents are served by request URI, or by path
+		key := r.URL.RequestURI()
+		if _, ok := s.docs[key]; !ok {
+			key = r.URL.Path
+		}
+		doc, ok := s.docs[key]
+		for k, v := range s.header[key] {
+			w.Header()[k] = v
+		}
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	return s
+}
+
+func (s *testAdServer) handle(path, doc string, h http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[path] = doc
+	s.header[path] = h
+}
+
+func (s *testAdServer) hitCount(uri string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[uri]
+}
+
+func wrapperDoc(next string) string {
+	return `<VAST version="3.0"><Ad id="w"><Wrapper><AdSystem>w</AdSystem><VASTAdTagURI><![CDATA[` + next + `]]></VASTAdTagURI></Wrapper></Ad></VAST>`
+}
+
+const inlineDoc = `<VAST version="3.0"><Ad id="inline"><InLine><AdSystem>i</AdSystem><AdTitle>t</AdTitle></InLine></Ad></VAST>`
+
+func TestResolverChain(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/w1", wrapperDoc(s.URL+"/w2?cb=[CACHEBUSTING]"), nil)
+	s.handle("/w2", wrapperDoc(s.URL+"/inline"), nil)
+	s.handle("/inline", inlineDoc, nil)
+
+	r := &Resolver{Macros: Macros{"CACHEBUSTING": "42"}}
+	v := &VAST{Ads: []Ad{
+		{ID: "root", Wrapper: &Wrapper{VASTAdTagURI: CDATAString{" " + s.URL + "/w1 "}}},
+		{ID: "direct", InLine: &InLine{}},
+	}}
+	res, err := r.Resolve(context.Background(), v)
+	if !assert.NoError(t, err) || !assert.Len(t, res, 2) {
+		return
+	}
+	assert.NoError(t, res[0].Err)
+	if assert.Len(t, res[0].Chain, 4) {
+		assert.Equal(t, "root", res[0].Chain[0].ID)
+		assert.Equal(t, "inline", res[0].InLine().ID)
+	}
+	assert.Equal(t, 1, s.hitCount("/w2?cb=42"))
+	assert.Equal(t, "direct", res[1].InLine().ID)
+}
+
+func TestResolverErrors(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/empty", `<VAST version="3.0"></VAST>`, nil)
+	s.handle("/bad", `<VAST><Ad>`, nil)
+	s.handle("/html", `<html><body>502 Bad Gateway</body></html>`, nil)
+	s.handle("/noversion", `<VAST></VAST>`, nil)
+	s.handle("/emptyad", `<VAST version="3.0"><Ad id="x"></Ad></VAST>`, nil)
+	s.handle("/big", inlineDoc, nil)
+	s.handle("/loop", wrapperDoc(s.URL+"/loop"), nil)
+
+	r := &Resolver{}
+	for path, code := range map[string]ErrorCode{
+		"/empty":     ErrorCodeWrapperNoAds,
+		"/bad":       ErrorCodeXMLParsing,
+		"/html":      ErrorCodeXMLParsing,
+		"/noversion": ErrorCodeSchemaValidation,
+		"/emptyad":   ErrorCodeSchemaValidation,
+		"/missing":   ErrorCodeWrapper,
+		"/loop":      ErrorCodeWrapperLimit,
+	} {
+		res := r.ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + path}}})
+		if assert.IsType(t, &Error{}, res.Err, path) {
+			assert.Equal(t, code, res.Err.(*Error).Code, path)
+		}
+		assert.Nil(t, res.InLine(), path)
+	}
+
+	r.MaxResponseSize = int64(len(inlineDoc) - 1)
+	_, err := r.Fetch(context.Background(), s.URL+"/big")
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeWrapper, err.(*Error).Code)
+	}
+
+	res := r.ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/emptyad"}}})
+	if assert.Len(t, res.Trace, 1) {
+		assert.Equal(t, ErrorCodeSchemaValidation, res.Trace[0].ErrorCode)
+	}
+	_, err = r.Resolve(context.Background(), &VAST{Ads: []Ad{{ID: "empty"}}})
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeSchemaValidation, err.(*Error).Code)
+	}
+
+	_, err = r.Resolve(context.Background(), &VAST{Ads: []Ad{{Wrapper: &Wrapper{}}}})
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, ErrorCodeWrapper, err.(*Error).Code)
+	}
+}
+
+func TestResolverTimeout(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer s.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res := (&Resolver{}).ResolveAd(ctx, Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL}}})
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperTimeout, res.Err.(*Error).Code)
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/cached", inlineDoc, http.Header{"Cache-Control": {"max-age=60"}})
+	s.handle("/nocache", inlineDoc, http.Header{"Cache-Control": {"no-store"}})
+	s.handle("/expires", strings.Replace(inlineDoc, "</InLine>", "<Expires>60</Expires></InLine>", 1), nil)
+
+	r := &Resolver{Cache: NewCache(NewMemoryCache(10, 0))}
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/cached", "/nocache", "/expires"} {
+			v, err := r.Fetch(context.Background(), s.URL+path)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "inline", v.Ads[0].ID)
+				// callers get their own copy
+				v.Ads[0].ID = "modified"
+			}
+		}
+	}
+	assert.Equal(t, 1, s.hitCount("/cached"))
+	assert.Equal(t, 3, s.hitCount("/nocache"))
+	assert.Equal(t, 1, s.hitCount("/expires"))
+
+	v, err := r.Fetch(context.Background(), s.URL+"/expires")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 60, v.Ads[0].InLine.Expires)
+		b, _ := xml.Marshal(v.Ads[0].InLine)
+		assert.Contains(t, string(b), "<Expires>60</Expires>")
+	}
+}
+
+type testFirer struct {
+	mu   sync.Mutex
+	m    Macros
+	urls []string
+}
+
+func (f *testFirer) FireURLs(ctx context.Context, m Macros, urls ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.m = m
+	f.urls = append(f.urls, urls...)
+	return nil
+}
+
+func TestResolverLoop(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	// A → B → A with a varying cache buster
+	s.handle("/a", `<VAST version="3.0"><Ad><Wrapper><AdSystem>A</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[`+s.URL+`/b]]></VASTAdTagURI><Error><![CDATA[http://a/err?c=[ERRORCODE]]]></Error></Wrapper></Ad></VAST>`, nil)
+	s.handle("/b", `<VAST version="3.0"><Ad><Wrapper><AdSystem>B</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[`+s.URL+`/a?cb=2]]></VASTAdTagURI><Error><![CDATA[http://b/err?c=[ERRORCODE]&u=[UID]]]></Error></Wrapper></Ad></VAST>`, nil)
+
+	f := &testFirer{}
+	r := &Resolver{Beacons: f, Macros: Macros{"UID": "u1"}}
+	res := r.ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{
+		AdSystem:     &AdSystem{Name: "A"},
+		VASTAdTagURI: CDATAString{s.URL + "/a?cb=1"},
+		Errors:       []CDATAString{{"http://root/err?c=[ERRORCODE]"}},
+	}})
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperLimit, res.Err.(*Error).Code)
+	}
+	// the second request of /b by A is detected, /a being requested by A
+	// then by B with a new cache buster
+	if assert.Len(t, res.Trace, 4) {
+		assert.Equal(t, s.URL+"/b", res.Trace[3].URL)
+	}
+	assert.Equal(t, 1, s.hitCount("/a?cb=1"))
+	assert.Equal(t, 1, s.hitCount("/a?cb=2"))
+	assert.Equal(t, 1, s.hitCount("/b"))
+	want := []string{"http://root/err?c=302", "http://a/err?c=302", "http://b/err?c=302&u=[UID]", "http://a/err?c=302"}
+	assert.Equal(t, want, res.ErrorURLs)
+	assert.Equal(t, want, f.urls)
+	assert.Equal(t, Macros{"UID": "u1"}, f.m)
+
+	// an ad tag requesting itself with a new cache buster
+	s.handle("/self", `<VAST version="3.0"><Ad><Wrapper><AdSystem>S</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[`+s.URL+`/self?correlator=[CACHEBUSTING]&iu=s]]></VASTAdTagURI></Wrapper></Ad></VAST>`, nil)
+	res = (&Resolver{}).ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/self?iu=s&correlator=1"}}})
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperLimit, res.Err.(*Error).Code)
+	}
+	assert.Len(t, res.Trace, 3)
+
+	// with custom cache busters
+	s.handle("/ts", `<VAST version="3.0"><Ad><Wrapper><AdSystem>T</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[`+s.URL+`/ts?ts=[TIMESTAMP]]]></VASTAdTagURI></Wrapper></Ad></VAST>`, nil)
+	ad := Ad{Wrapper: &Wrapper{AdSystem: &AdSystem{Name: "T"}, VASTAdTagURI: CDATAString{s.URL + "/ts?ts=1"}}}
+	res = (&Resolver{CacheBusters: []string{"TS"}}).ResolveAd(context.Background(), ad)
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperLimit, res.Err.(*Error).Code)
+	}
+	assert.Len(t, res.Trace, 2)
+	// ts=1 and ts=[TIMESTAMP] are told apart by default
+	res = (&Resolver{}).ResolveAd(context.Background(), ad)
+	assert.Len(t, res.Trace, 3)
+
+	// the same ad server serving different ad tags, told apart by their
+	// query, is not a loop
+	s.handle("/gampad/ads", `<VAST version="3.0"><Ad><Wrapper><AdSystem>GDFP</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[`+s.URL+`/gampad/ads?iu=B&correlator=2]]></VASTAdTagURI></Wrapper></Ad></VAST>`, nil)
+	s.handle("/gampad/ads?iu=B&correlator=2", inlineDoc, nil)
+	res = (&Resolver{}).ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{
+		AdSystem:     &AdSystem{Name: "GDFP"},
+		VASTAdTagURI: CDATAString{s.URL + "/gampad/ads?iu=A&correlator=1"},
+	}})
+	assert.NoError(t, res.Err)
+	assert.NotNil(t, res.InLine())
+}
+
+func TestResolverMaxDepth(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/1", wrapperDoc(s.URL+"/2"), nil)
+	s.handle("/2", wrapperDoc(s.URL+"/3"), nil)
+	s.handle("/3", inlineDoc, nil)
+
+	ad := Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/1"}}}
+	res := (&Resolver{MaxDepth: 2}).ResolveAd(context.Background(), ad)
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperLimit, res.Err.(*Error).Code)
+	}
+	assert.Equal(t, 0, s.hitCount("/3"))
+	res = (&Resolver{MaxDepth: 3}).ResolveAd(context.Background(), ad)
+	assert.NoError(t, res.Err)
+}
+
+func TestResolverBudget(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer s.Close()
+	defer close(done)
+
+	f := &testFirer{}
+	r := &Resolver{Timeout: 20 * time.Millisecond, Beacons: f}
+	res := r.ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{
+		VASTAdTagURI: CDATAString{s.URL},
+		Errors:       []CDATAString{{"http://root/err?c=[ERRORCODE]"}},
+	}})
+	if assert.IsType(t, &Error{}, res.Err) {
+		assert.Equal(t, ErrorCodeWrapperTimeout, res.Err.(*Error).Code)
+	}
+	assert.Equal(t, []string{"http://root/err?c=301"}, f.urls)
+}
+
+func TestResolverNoAdErrorURLs(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/empty", `<VAST version="3.0"><Error><![CDATA[http://partner/err?c=[ERRORCODE]]]></Error></VAST>`, nil)
+	res := (&Resolver{}).ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{
+		VASTAdTagURI: CDATAString{s.URL + "/empty"},
+		Errors:       []CDATAString{{"http://root/err?c=[ERRORCODE]"}},
+	}})
+	assert.Equal(t, []string{"http://root/err?c=303", "http://partner/err?c=303"}, res.ErrorURLs)
+}
+
+func podDoc(s *testAdServer) *VAST {
+	return &VAST{Ads: []Ad{
+		{ID: "b1", Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/missing"}}},
+		{ID: "p3", Sequence: 3, Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/inline?p=3"}}},
+		{ID: "p1", Sequence: 1, Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/inline?p=1"}}},
+		{ID: "b2", Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/inline?b=2"}}},
+		{ID: "p2", Sequence: 2, Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/missing?p=2"}}},
+		{ID: "b3", Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/inline?b=3"}}},
+	}}
+}
+
+func TestResolvePod(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/inline", inlineDoc, nil)
+
+	res, err := (&Resolver{}).ResolvePod(context.Background(), podDoc(s))
+	if !assert.NoError(t, err) || !assert.Len(t, res, 3) {
+		return
+	}
+	var ids []string
+	for _, ra := range res {
+		assert.NoError(t, ra.Err)
+		ids = append(ids, ra.Chain[0].ID)
+	}
+	assert.Equal(t, []string{"p1", "b2", "p3"}, ids)
+	assert.Equal(t, []bool{false, true, false}, []bool{res[0].Fallback, res[1].Fallback, res[2].Fallback})
+	assert.Equal(t, 1, s.hitCount("/missing"))
+	assert.Equal(t, 0, s.hitCount("/inline?b=3"), "buffet ads resolved only as needed")
+
+	// no buffet ad left to replace the failed ad
+	v := podDoc(s)
+	v.Ads = []Ad{v.Ads[1], v.Ads[2], v.Ads[4]}
+	res, err = (&Resolver{}).ResolvePod(context.Background(), v)
+	if assert.NoError(t, err) && assert.Len(t, res, 3) {
+		assert.Equal(t, "p2", res[1].Chain[0].ID)
+		if assert.IsType(t, &Error{}, res[1].Err) {
+			assert.Equal(t, ErrorCodeWrapper, res[1].Err.(*Error).Code)
+		}
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	var inflight, max int
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		if inflight > max {
+			max = inflight
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+		w.Write([]byte(inlineDoc))
+	}))
+	defer s.Close()
+
+	v := &VAST{}
+	for i := 0; i < 5; i++ {
+		v.Ads = append(v.Ads, Ad{ID: strconv.Itoa(i), Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL}}})
+	}
+	done := make(chan []ResolvedAd)
+	go func() {
+		res, err := (&Resolver{MaxConcurrency: 2}).Resolve(context.Background(), v)
+		assert.NoError(t, err)
+		done <- res
+	}()
+	// release the requests one at a time once the limit is reached
+	for i := 0; i < len(v.Ads); i++ {
+		for {
+			mu.Lock()
+			n := inflight
+			mu.Unlock()
+			if n == 2 || (i >= len(v.Ads)-1 && n == 1) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		release <- struct{}{}
+	}
+	res := <-done
+	assert.Equal(t, 2, max)
+	for i, ra := range res {
+		assert.NoError(t, ra.Err)
+		assert.Equal(t, strconv.Itoa(i), ra.Chain[0].ID)
+	}
+}
+
+func TestResolveTotalTimeout(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-done
+		}
+		w.Write([]byte(inlineDoc))
+	}))
+	defer s.Close()
+	defer close(done)
+
+	v := &VAST{Ads: []Ad{
+		{Sequence: 1, Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/fast"}}},
+		{Sequence: 2, Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/slow"}}},
+		{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/fast"}}},
+	}}
+	res, err := (&Resolver{TotalTimeout: 50 * time.Millisecond}).ResolvePod(context.Background(), v)
+	if assert.NoError(t, err) && assert.Len(t, res, 2) {
+		assert.NoError(t, res[0].Err)
+		if assert.IsType(t, &Error{}, res[1].Err) {
+			assert.Equal(t, ErrorCodeWrapperTimeout, res[1].Err.(*Error).Code)
+		}
+	}
+}
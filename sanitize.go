@@ -0,0 +1,522 @@
+package vast
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// DefaultAllowedTags are the HTML elements kept by a SanitizePolicy with no
+// AllowedTags.
+var DefaultAllowedTags = []string{
+	"a", "b", "br", "center", "div", "em", "font", "h1", "h2", "h3", "h4",
+	"h5", "h6", "hr", "i", "img", "li", "ol", "p", "small", "span", "strong",
+	"table", "tbody", "td", "th", "thead", "tr", "u", "ul",
+}
+
+// Decode returns the HTML of the resource, un-escaping it if it is
+// XML-encoded.
+func (r *HTMLResource) Decode() string {
+	if r.XMLEncoded {
+		return html.UnescapeString(r.HTML)
+	}
+	return r.HTML
+}
+
+// SanitizePolicy defines how third party HTML, iframe and static resources
+// are sanitized.
+type SanitizePolicy struct {
+	// AllowedTags lists the HTML elements to keep, in lower case.
+	// DefaultAllowedTags is used if nil. Other elements are removed, keeping
+	// their content, except for script, style, iframe, object and embed
+	// elements which are removed with their content.
+	AllowedTags []string
+	// AllowScripts keeps script elements, event handler attributes (onclick...),
+	// iframe srcdoc attributes and javascript: URLs.
+	AllowScripts bool
+	// HTTPSOnly removes the resources and attributes referencing a non https
+	// URL.
+	HTTPSOnly bool
+	// AllowedDomains, if not empty, lists the domains resources may be loaded
+	// from. Sub-domains of listed domains are allowed.
+	AllowedDomains []string
+}
+
+// AllowURL returns true if the policy allows loading a resource from u.
+func (p SanitizePolicy) AllowURL(u string) bool {
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(pu.Scheme) {
+	case "https":
+	case "http":
+		if p.HTTPSOnly {
+			return false
+		}
+	case "":
+		// scheme relative URLs inherit the https scheme of secure pages
+		if pu.Host == "" {
+			return len(p.AllowedDomains) == 0
+		}
+	case "javascript":
+		return p.AllowScripts
+	default:
+		return false
+	}
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	host := strings.ToLower(pu.Hostname())
+	for _, d := range p.AllowedDomains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeHTML returns s with the elements and attributes not allowed by the
+// policy removed.
+func (p SanitizePolicy) SanitizeHTML(s string) string {
+	clean, _ := p.sanitizeHTML(s)
+	return clean
+}
+
+// sanitizeHTML returns s sanitized as SanitizeHTML does, and the number of
+// comments, elements and attributes removed, lone < escaped and end tags
+// added or removed to balance the elements.
+func (p SanitizePolicy) sanitizeHTML(s string) (string, int) {
+	removed := 0
+	allowed := p.AllowedTags
+	if allowed == nil {
+		allowed = DefaultAllowedTags
+	}
+	tags := make(map[string]bool, len(allowed))
+	for _, t := range allowed {
+		tags[t] = true
+	}
+	if p.AllowScripts {
+		tags["script"] = true
+	}
+	var b strings.Builder
+	// the elements written and not closed yet
+	var open []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i == -1 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		if strings.HasPrefix(s, "<!--") {
+			// comments may hide conditional markup, drop them
+			removed++
+			if j := strings.Index(s, "-->"); j != -1 {
+				s = s[j+3:]
+			} else {
+				s = ""
+			}
+			continue
+		}
+		t, n := parseTag(s)
+		if n == 0 {
+			// not a tag, escape the lone <
+			removed++
+			b.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = s[n:]
+		if !tags[t.name] {
+			removed++
+			if !t.end && !t.selfClosing && removeContent[t.name] {
+				s = skipElement(s, t.name)
+			}
+			continue
+		}
+		if t.end {
+			j := len(open) - 1
+			for j >= 0 && open[j] != t.name {
+				j--
+			}
+			if j == -1 {
+				// the start tag was removed or never written
+				removed++
+				continue
+			}
+			// close the elements left open inside this one
+			for k := len(open) - 1; k > j; k-- {
+				removed++
+				b.WriteString("</" + open[k] + ">")
+			}
+			open = open[:j]
+			b.WriteString("</" + t.name + ">")
+			continue
+		}
+		if t.name == "script" && t.src() != "" && !p.AllowURL(t.src()) {
+			removed++
+			s = skipElement(s, t.name)
+			continue
+		}
+		b.WriteString("<" + t.name)
+		for _, a := range t.attrs {
+			if !p.allowAttr(a) {
+				removed++
+				continue
+			}
+			b.WriteString(" " + a.name)
+			if a.hasValue {
+				b.WriteString(`="` + html.EscapeString(a.value) + `"`)
+			}
+		}
+		if t.selfClosing {
+			b.WriteString("/")
+		} else if !voidElements[t.name] {
+			open = append(open, t.name)
+		}
+		b.WriteString(">")
+		if t.name == "script" && !t.selfClosing {
+			// keep the script body as is, it is not HTML
+			j := indexEndTag(s, "script")
+			if j == -1 {
+				j = len(s)
+			}
+			b.WriteString(s[:j])
+			s = s[j:]
+		}
+	}
+	for k := len(open) - 1; k >= 0; k-- {
+		removed++
+		b.WriteString("</" + open[k] + ">")
+	}
+	return b.String(), removed
+}
+
+// voidElements lists the elements which have no content nor end tag.
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// removeContent lists the elements removed along with their content when not
+// allowed.
+var removeContent = map[string]bool{
+	"script": true,
+	"style":  true,
+	"iframe": true,
+	"object": true,
+	"embed":  true,
+}
+
+// urlAttrs lists the attributes holding a URL.
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"lowsrc":     true,
+	"poster":     true,
+	"src":        true,
+	"xlink:href": true,
+}
+
+func (p SanitizePolicy) allowAttr(a htmlAttr) bool {
+	switch {
+	case strings.HasPrefix(a.name, "on"):
+		return p.AllowScripts
+	case urlAttrs[a.name]:
+		return p.AllowURL(a.value)
+	case a.name == "style":
+		return p.allowStyle(a.value)
+	case a.name == "srcdoc":
+		// the document of an iframe, with its own scripts and resources
+		return p.AllowScripts
+	case a.name == "ping":
+		// space separated list of URLs
+		for _, u := range strings.Fields(a.value) {
+			if !p.AllowURL(u) {
+				return false
+			}
+		}
+	case a.name == "srcset" || a.name == "imagesrcset":
+		// comma separated list of URLs, each followed by an optional
+		// descriptor (e.g. "img.png 2x")
+		for _, c := range strings.Split(a.value, ",") {
+			if f := strings.Fields(c); len(f) > 0 && !p.AllowURL(f[0]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// allowStyle returns true if the policy allows all the URLs referenced by
+// the inline style s. Styles with escapes, imports, expressions or image sets,
+// which may hide URLs, are not allowed.
+func (p SanitizePolicy) allowStyle(s string) bool {
+	s = strings.ToLower(s)
+	for _, bad := range []string{`\`, "@import", "expression(", "image-set("} {
+		if strings.Contains(s, bad) {
+			return false
+		}
+	}
+	for {
+		i := strings.Index(s, "url(")
+		if i == -1 {
+			return true
+		}
+		s = strings.TrimLeft(s[i+4:], " \t\n\r\f")
+		end := ")"
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end, s = s[:1], s[1:]
+		}
+		j := strings.Index(s, end)
+		if j == -1 || !p.AllowURL(s[:j]) {
+			return false
+		}
+		s = s[j+1:]
+	}
+}
+
+type htmlAttr struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+type htmlTag struct {
+	name        string
+	end         bool
+	selfClosing bool
+	attrs       []htmlAttr
+}
+
+func (t htmlTag) src() string {
+	for _, a := range t.attrs {
+		if a.name == "src" {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// parseTag parses the start or end tag at the beginning of s and returns it
+// with its length, or a zero length if s does not start with a tag.
+func parseTag(s string) (htmlTag, int) {
+	var t htmlTag
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.end = true
+		i++
+	}
+	start := i
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return htmlTag{}, 0
+	}
+	t.name = strings.ToLower(s[start:i])
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return htmlTag{}, 0
+		}
+		switch s[i] {
+		case '>':
+			return t, i + 1
+		case '/':
+			if i+1 < len(s) && s[i+1] == '>' {
+				t.selfClosing = true
+				return t, i + 2
+			}
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		a := htmlAttr{name: strings.ToLower(s[start:i])}
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i >= len(s) {
+				return htmlTag{}, 0
+			}
+			a.hasValue = true
+			if q := s[i]; q == '"' || q == '\'' {
+				j := strings.IndexByte(s[i+1:], q)
+				if j == -1 {
+					return htmlTag{}, 0
+				}
+				a.value = s[i+1 : i+1+j]
+				i += j + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				a.value = s[start:i]
+			}
+			a.value = html.UnescapeString(a.value)
+		}
+		if !t.end {
+			t.attrs = append(t.attrs, a)
+		}
+	}
+}
+
+// skipElement returns s after the end tag of the element name, or an empty
+// string if it has none.
+func skipElement(s, name string) string {
+	i := indexEndTag(s, name)
+	if i == -1 {
+		return ""
+	}
+	s = s[i:]
+	if j := strings.IndexByte(s, '>'); j != -1 {
+		return s[j+1:]
+	}
+	return ""
+}
+
+// indexEndTag returns the index of the end tag of the element name in s, or
+// -1 if not found.
+func indexEndTag(s, name string) int {
+	lower := strings.ToLower(s)
+	off := 0
+	for {
+		i := strings.Index(lower[off:], "</"+name)
+		if i == -1 {
+			return -1
+		}
+		i += off
+		if e := i + 2 + len(name); e >= len(s) || !isNameChar(s[e]) {
+			return i
+		}
+		off = i + 2
+	}
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || '0' <= c && c <= '9' || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Sanitize applies the policy to the HTML, iframe and static resources of all
+// the companions, non-linears and icons of v. HTML resources are decoded and
+// sanitized; iframe and static resources referencing a URL not allowed by the
+// policy are removed. It returns the number of resources from which something
+// was removed.
+func (p SanitizePolicy) Sanitize(v *VAST) int {
+	s := sanitizer{policy: p}
+	for i := range v.Ads {
+		if in := v.Ads[i].InLine; in != nil {
+			for j := range in.Creatives {
+				c := &in.Creatives[j]
+				if c.Linear != nil {
+					s.icons(c.Linear.Icons)
+				}
+				if c.CompanionAds != nil {
+					for k := range c.CompanionAds.Companions {
+						comp := &c.CompanionAds.Companions[k]
+						s.resources(&comp.StaticResource, &comp.IFrameResource, &comp.HTMLResource)
+					}
+				}
+				if c.NonLinearAds != nil {
+					for k := range c.NonLinearAds.NonLinears {
+						nl := &c.NonLinearAds.NonLinears[k]
+						s.resources(&nl.StaticResource, &nl.IFrameResource, &nl.HTMLResource)
+					}
+				}
+			}
+		}
+		if w := v.Ads[i].Wrapper; w != nil {
+			for j := range w.Creatives {
+				c := &w.Creatives[j]
+				if c.Linear != nil {
+					s.icons(c.Linear.Icons)
+				}
+				if c.CompanionAds != nil {
+					for k := range c.CompanionAds.Companions {
+						comp := &c.CompanionAds.Companions[k]
+						s.resources(&comp.StaticResource, &comp.IFrameResource, &comp.HTMLResource)
+					}
+				}
+			}
+		}
+	}
+	return s.modified
+}
+
+type sanitizer struct {
+	policy   SanitizePolicy
+	modified int
+}
+
+func (s *sanitizer) icons(icons *Icons) {
+	if icons == nil {
+		return
+	}
+	for i := range icons.Icon {
+		icon := &icons.Icon[i]
+		s.resources(&icon.StaticResource, &icon.IFrameResource, &icon.HTMLResource)
+	}
+}
+
+func (s *sanitizer) resources(static **StaticResource, iframe *CDATAString, h **HTMLResource) {
+	if *static != nil && !s.policy.AllowURL((*static).URI) {
+		*static = nil
+		s.modified++
+	}
+	if u := strings.TrimSpace(iframe.CDATA); u != "" && !s.policy.AllowURL(u) {
+		iframe.CDATA = ""
+		s.modified++
+	}
+	if *h != nil {
+		clean, removed := s.policy.sanitizeHTML((*h).Decode())
+		if strings.TrimSpace(clean) == "" {
+			*h = nil
+			s.modified++
+			return
+		}
+		// resources with nothing removed are left as is, encoding included
+		if removed > 0 {
+			(*h).HTML = clean
+			(*h).XMLEncoded = false
+			s.modified++
+		}
+	}
+}
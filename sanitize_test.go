@@ -0,0 +1,100 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLResourceDecode(t *testing.T) {
+	r := &HTMLResource{XMLEncoded: true, HTML: "&lt;b&gt;hi &amp;amp; bye&lt;/b&gt;"}
+	assert.Equal(t, "<b>hi &amp; bye</b>", r.Decode())
+	r.XMLEncoded = false
+	assert.Equal(t, r.HTML, r.Decode())
+}
+
+func TestSanitizePolicyAllowURL(t *testing.T) {
+	p := SanitizePolicy{HTTPSOnly: true, AllowedDomains: []string{"cdn.com"}}
+	assert.True(t, p.AllowURL(" https://cdn.com/a.png "))
+	assert.True(t, p.AllowURL("https://img.CDN.com/a.png"))
+	assert.True(t, p.AllowURL("//img.cdn.com/a.png"))
+	assert.False(t, p.AllowURL("http://cdn.com/a.png"))
+	assert.False(t, p.AllowURL("https://evilcdn.com/a.png"))
+	assert.False(t, p.AllowURL("/a.png"))
+	assert.False(t, p.AllowURL("javascript:alert(1)"))
+	assert.False(t, p.AllowURL("data:text/html,hi"))
+
+	p = SanitizePolicy{}
+	assert.True(t, p.AllowURL("http://any.com/a.png"))
+	assert.True(t, p.AllowURL("a.png"))
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	p := SanitizePolicy{HTTPSOnly: true}
+	for in, out := range map[string]string{
+		`<div class="x" onclick="steal()"><b>Buy</b> now</div>`:                                 `<div class="x"><b>Buy</b> now</div>`,
+		`<script src="https://evil/x.js"></script><p>ok</p>`:                                    `<p>ok</p>`,
+		`<SCRIPT>alert("<p>")</Script ><p>ok</p>`:                                               `<p>ok</p>`,
+		`<style>body{}</style><iframe src="https://x"><p>no</p></iframe>`:                       ``,
+		`<a href="javascript:alert(1)" target=_blank>x</a>`:                                     `<a target="_blank">x</a>`,
+		`<img src='http://cdn/a.png' alt="a &quot;b&quot;"/>`:                                   `<img alt="a &#34;b&#34;"/>`,
+		`<blink>text</blink> 1 < 2 <!-- <script>x</script> -->`:                                 `text 1 &lt; 2 `,
+		`<a href="https://cdn/?a=1&amp;b=2">x</a><unknown-tag attr>y</unknown-tag>`:             `<a href="https://cdn/?a=1&amp;b=2">x</a>y`,
+		`<img srcset="https://cdn/a.png 1x, javascript:alert(1) 2x" lowsrc="http://cdn/l.png">`: `<img>`,
+		`<img srcset="https://cdn/a.png 1x,https://cdn/b.png 2x" alt=a>`:                        `<img srcset="https://cdn/a.png 1x,https://cdn/b.png 2x" alt="a">`,
+		`<a ping="https://t/1 http://t/2" href="https://x">x</a>`:                               `<a href="https://x">x</a>`,
+		`<a ping="https://t/1  https://t/2">x</a>`:                                              `<a ping="https://t/1  https://t/2">x</a>`,
+		`<div background="javascript:x()" poster="http://p">x</div>`:                            `<div>x</div>`,
+		`<div style="background-image:url(http://evil.com/p.gif)">x</div>`:                      `<div>x</div>`,
+		`<div style='background:URL( "http://evil.com/p.gif" )'>x</div>`:                        `<div>x</div>`,
+		`<div style="color:red;background:url('https://cdn/a.png')">x</div>`:                    `<div style="color:red;background:url(&#39;https://cdn/a.png&#39;)">x</div>`,
+		`<div style="background:\75 rl(http://evil.com/p.gif)">x</div>`:                         `<div>x</div>`,
+		`<div style="background:image-set('http://evil.com/p.gif' 1x)">x</div>`:                 `<div>x</div>`,
+		`<div><b>bold</div> after`:                                                              `<div><b>bold</b></div> after`,
+		`</p><p>open`:                                                                           `<p>open</p>`,
+		`<p><blink>x</p></blink><br>`:                                                           `<p>x</p><br>`,
+	} {
+		assert.Equal(t, out, p.SanitizeHTML(in), in)
+	}
+
+	p = SanitizePolicy{AllowedTags: []string{"iframe"}}
+	assert.Equal(t, `<iframe></iframe>`, p.SanitizeHTML(`<iframe srcdoc="<img src=http://evil.com/p.gif>"></iframe>`))
+	p.AllowedDomains = []string{"cdn.com"}
+	assert.Equal(t, `<iframe></iframe>`, p.SanitizeHTML(`<iframe style="background:url(//evil.com/p.gif)"></iframe>`))
+
+	p = SanitizePolicy{AllowScripts: true, AllowedTags: []string{"p"}}
+	assert.Equal(t, `<script src="https://cdn/x.js"></script><p onclick="go()">x</p>`,
+		p.SanitizeHTML(`<script src="https://cdn/x.js"></script><p onclick="go()">x</p>`))
+	assert.Equal(t, `<script>if (a < b) {}</script>`, p.SanitizeHTML(`<script>if (a < b) {}</script>`))
+
+	p = SanitizePolicy{AllowedTags: []string{"svg", "use"}}
+	assert.Equal(t, `<svg><use/></svg>`, p.SanitizeHTML(`<svg><use xlink:href="javascript:alert(1)"/></svg>`))
+}
+
+func TestSanitizeVAST(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast_inline_linear.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	comps := v.Ads[0].InLine.Creatives[1].CompanionAds.Companions
+	comps[0].HTMLResource = &HTMLResource{XMLEncoded: true, HTML: "&lt;p onload=&quot;x()&quot;&gt;hi&lt;/p&gt;"}
+	comps[1].IFrameResource = CDATAString{"https://evil.com/frame.html"}
+	comps[1].HTMLResource = &HTMLResource{HTML: "<script>x()</script>"}
+
+	p := SanitizePolicy{AllowedDomains: []string{"tremormedia.com"}}
+	assert.Equal(t, 3, p.Sanitize(v))
+	assert.Equal(t, &HTMLResource{HTML: "<p>hi</p>"}, comps[0].HTMLResource)
+	assert.NotNil(t, comps[0].StaticResource)
+	assert.Equal(t, "", comps[1].IFrameResource.CDATA)
+	assert.Nil(t, comps[1].HTMLResource)
+
+	p = SanitizePolicy{HTTPSOnly: true}
+	assert.Equal(t, 2, p.Sanitize(v))
+	assert.Nil(t, comps[0].StaticResource)
+	assert.Nil(t, comps[1].StaticResource)
+
+	// XML-encoded resources with nothing to remove are left as is
+	comps[0].HTMLResource = &HTMLResource{XMLEncoded: true, HTML: "&lt;P CLASS=a&gt;hi&lt;/P&gt;"}
+	assert.Equal(t, 0, p.Sanitize(v))
+	assert.Equal(t, &HTMLResource{XMLEncoded: true, HTML: "&lt;P CLASS=a&gt;hi&lt;/P&gt;"}, comps[0].HTMLResource)
+}
@@ -0,0 +1,134 @@
+package vast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Hop describes a wrapper followed during the resolution of an ad.
+type Hop struct {
+	// Depth of the hop, 1 for the ad tag of the ad of the original document.
+	Depth int `json:"depth"`
+	// URL is the ad tag requested, with its macros expanded.
+	URL string `json:"url"`
+	// Status is the HTTP status of the response, 0 if no response was
+	// received or if it was served from the cache.
+	Status int `json:"status,omitempty"`
+	// Latency is the time spent fetching and decoding the response.
+	Latency time.Duration `json:"-"`
+	// Bytes is the size of the response body.
+	Bytes int `json:"bytes"`
+	// Cached is true if the response was served from the cache or shared with
+	// a concurrent request.
+	Cached bool `json:"cached,omitempty"`
+	// AdSystem is the name and version of the ad server of the ad received.
+	AdSystem        string `json:"ad_system,omitempty"`
+	AdSystemVersion string `json:"ad_system_version,omitempty"`
+	// Warnings lists the anomalies found while decoding the response.
+	Warnings []string `json:"warnings,omitempty"`
+	// ErrorCode is the VAST error code assigned to the failure of the hop, 0
+	// if it succeeded.
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+	// Error is the message of the failure of the hop.
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface, the latency being
+// encoded in milliseconds.
+func (h Hop) MarshalJSON() ([]byte, error) {
+	type hop Hop
+	return json.Marshal(struct {
+		hop
+		LatencyMS float64 `json:"latency_ms"`
+	}{hop(h), float64(h.Latency) / float64(time.Millisecond)})
+}
+
+func (h *Hop) warn(msg string) {
+	h.Warnings = append(h.Warnings, msg)
+}
+
+func (h *Hop) setError(err error) {
+	h.Error = err.Error()
+	h.ErrorCode = ErrorCodeUndefined
+	if e, ok := err.(*Error); ok {
+		h.Error = e.Message
+		h.ErrorCode = e.Code
+	}
+}
+
+func (h *Hop) setAdSystem(ad *Ad) {
+	var sys *AdSystem
+	switch {
+	case ad.InLine != nil:
+		sys = ad.InLine.AdSystem
+	case ad.Wrapper != nil:
+		sys = ad.Wrapper.AdSystem
+	}
+	if sys != nil {
+		h.AdSystem = strings.TrimSpace(sys.Name)
+		h.AdSystemVersion = sys.Version
+	}
+}
+
+// Trace lists the hops of the resolution of an ad.
+type Trace []Hop
+
+// Failed returns the hop which failed, or nil if all hops succeeded.
+func (t Trace) Failed() *Hop {
+	for i := range t {
+		if t[i].ErrorCode != 0 {
+			return &t[i]
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the trace to w as a human readable tree, one hop per line.
+func (t Trace) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for i, h := range t {
+		if i > 0 {
+			b.WriteString(strings.Repeat("   ", i-1))
+			b.WriteString("└─ ")
+		}
+		b.WriteString(h.URL)
+		if h.URL == "" {
+			b.WriteString("(no URL)")
+		}
+		var details []string
+		if h.Status != 0 {
+			details = append(details, fmt.Sprintf("status %d", h.Status))
+		}
+		if h.Cached {
+			details = append(details, "cached")
+		}
+		details = append(details, h.Latency.Round(time.Microsecond).String(), fmt.Sprintf("%d bytes", h.Bytes))
+		fmt.Fprintf(&b, " [%s]", strings.Join(details, ", "))
+		if h.AdSystem != "" {
+			fmt.Fprintf(&b, " %s", h.AdSystem)
+			if h.AdSystemVersion != "" {
+				fmt.Fprintf(&b, " %s", h.AdSystemVersion)
+			}
+		}
+		if h.ErrorCode != 0 {
+			fmt.Fprintf(&b, " error %d: %s", h.ErrorCode, h.Error)
+		}
+		b.WriteByte('\n')
+		for _, warn := range h.Warnings {
+			b.WriteString(strings.Repeat("   ", i))
+			fmt.Fprintf(&b, "   warning: %s\n", warn)
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// String returns the trace as a human readable tree.
+func (t Trace) String() string {
+	var b strings.Builder
+	t.WriteTo(&b)
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package vast
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolverTrace(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/w1", wrapperDoc(s.URL+"/w2"), nil)
+	s.handle("/w2", `<VAST><Ad><Wrapper><AdSystem version="2.1">partner</AdSystem><VASTAdTagURI>`+s.URL+`/empty</VASTAdTagURI></Wrapper></Ad><Ad/></VAST>`, nil)
+	s.handle("/empty", `<VAST version="3.0"/>`, nil)
+
+	res := (&Resolver{}).ResolveAd(context.Background(), Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/w1"}}})
+	if !assert.Len(t, res.Trace, 3) {
+		return
+	}
+	h := res.Trace[1]
+	assert.Equal(t, 2, h.Depth)
+	assert.Equal(t, s.URL+"/w2", h.URL)
+	assert.Equal(t, 200, h.Status)
+	assert.True(t, h.Latency > 0)
+	assert.Equal(t, "partner", h.AdSystem)
+	assert.Equal(t, "2.1", h.AdSystemVersion)
+	assert.Equal(t, []string{"missing VAST version", "ad 1 has neither InLine nor Wrapper", "2 ads in response, following the first one"}, h.Warnings)
+
+	failed := res.Trace.Failed()
+	if assert.NotNil(t, failed) {
+		assert.Equal(t, 3, failed.Depth)
+		assert.Equal(t, ErrorCodeWrapperNoAds, failed.ErrorCode)
+	}
+	assert.Equal(t, res.Err.(*Error).Code, failed.ErrorCode)
+}
+
+func TestTraceCached(t *testing.T) {
+	s := newTestAdServer()
+	defer s.Close()
+	s.handle("/inline", inlineDoc, map[string][]string{"Cache-Control": {"max-age=60"}})
+
+	r := &Resolver{Cache: NewCache(NewMemoryCache(0, 0))}
+	ad := Ad{Wrapper: &Wrapper{VASTAdTagURI: CDATAString{s.URL + "/inline"}}}
+	res := r.ResolveAd(context.Background(), ad)
+	assert.False(t, res.Trace[0].Cached)
+	res = r.ResolveAd(context.Background(), ad)
+	assert.True(t, res.Trace[0].Cached)
+	assert.Equal(t, 0, res.Trace[0].Status)
+	assert.Equal(t, len(inlineDoc), res.Trace[0].Bytes)
+}
+
+func TestTraceFormat(t *testing.T) {
+	tr := Trace{
+		{Depth: 1, URL: "http://a/w1", Status: 200, Latency: 12 * time.Millisecond, Bytes: 345, AdSystem: "w", AdSystemVersion: "1.0"},
+		{Depth: 2, URL: "http://b/w2", Latency: 1500 * time.Microsecond, Bytes: 300, Cached: true, Warnings: []string{"missing VAST version"}},
+		{Depth: 3, URL: "http://c/w3", Status: 500, Latency: 3 * time.Millisecond, ErrorCode: ErrorCodeWrapper, Error: "ad tag returned status 500"},
+	}
+	assert.Equal(t, `http://a/w1 [status 200, 12ms, 345 bytes] w 1.0
+└─ http://b/w2 [cached, 1.5ms, 300 bytes]
+      warning: missing VAST version
+   └─ http://c/w3 [status 500, 3ms, 0 bytes] error 300: ad tag returned status 500
+`, tr.String())
+
+	b, err := json.Marshal(tr[1:])
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[
+			{"depth":2,"url":"http://b/w2","bytes":300,"cached":true,"warnings":["missing VAST version"],"latency_ms":1.5},
+			{"depth":3,"url":"http://c/w3","status":500,"bytes":0,"error_code":300,"error":"ad tag returned status 500","latency_ms":3}
+		]`, string(b))
+	}
+	assert.Nil(t, tr[:2].Failed())
+}
@@ -0,0 +1,325 @@
+package vast
+
+import "strings"
+
+// ImpressionURLs returns the impression tracking URLs of the ad.
+func (ad *Ad) ImpressionURLs() []string {
+	var imps []Impression
+	switch {
+	case ad.InLine != nil:
+		imps = ad.InLine.Impressions
+	case ad.Wrapper != nil:
+		imps = ad.Wrapper.Impressions
+	}
+	var urls []string
+	for _, imp := range imps {
+		urls = appendURL(urls, imp.URI)
+	}
+	return urls
+}
+
+// ErrorURLs returns the error tracking URLs of the ad.
+func (ad *Ad) ErrorURLs() []string {
+	var errs []CDATAString
+	switch {
+	case ad.InLine != nil:
+		errs = ad.InLine.Errors
+	case ad.Wrapper != nil:
+		errs = ad.Wrapper.Errors
+	}
+	var urls []string
+	for _, e := range errs {
+		urls = appendURL(urls, e.CDATA)
+	}
+	return urls
+}
+
+// TrackingURLs returns the URLs of the linear, non linear and companion
+// tracking events of the ad matching event (e.g. "start" or "complete").
+func (ad *Ad) TrackingURLs(event string) []string {
+	var urls []string
+	add := func(trackings []Tracking) {
+		for _, t := range trackings {
+			if t.Event == event {
+				urls = appendURL(urls, t.URI)
+			}
+		}
+	}
+	if ad.InLine != nil {
+		for _, c := range ad.InLine.Creatives {
+			if c.Linear != nil {
+				add(c.Linear.TrackingEvents)
+			}
+			if c.NonLinearAds != nil {
+				add(c.NonLinearAds.TrackingEvents)
+			}
+			if c.CompanionAds != nil {
+				for _, comp := range c.CompanionAds.Companions {
+					add(comp.TrackingEvents)
+				}
+			}
+		}
+	}
+	if ad.Wrapper != nil {
+		for _, c := range ad.Wrapper.Creatives {
+			if c.Linear != nil {
+				add(c.Linear.TrackingEvents)
+			}
+			if c.NonLinearAds != nil {
+				add(c.NonLinearAds.TrackingEvents)
+				for _, nl := range c.NonLinearAds.NonLinears {
+					add(nl.TrackingEvents)
+				}
+			}
+			if c.CompanionAds != nil {
+				for _, comp := range c.CompanionAds.Companions {
+					add(comp.TrackingEvents)
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// ClickTrackingURLs returns the URLs to ping when the user clicks on the
+// linear creatives of the ad.
+func (ad *Ad) ClickTrackingURLs() []string {
+	var urls []string
+	add := func(vc *VideoClicks) {
+		if vc == nil {
+			return
+		}
+		for _, c := range vc.ClickTrackings {
+			urls = appendURL(urls, c.URI)
+		}
+	}
+	if ad.InLine != nil {
+		for _, c := range ad.InLine.Creatives {
+			if c.Linear != nil {
+				add(c.Linear.VideoClicks)
+			}
+		}
+	}
+	if ad.Wrapper != nil {
+		for _, c := range ad.Wrapper.Creatives {
+			if c.Linear != nil {
+				add(c.Linear.VideoClicks)
+			}
+		}
+	}
+	return urls
+}
+
+// appendURL appends u to urls, ignoring the surrounding whitespace and empty
+// URLs.
+func appendURL(urls []string, u string) []string {
+	if u = strings.TrimSpace(u); u != "" {
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// RewriteURLs replaces every URL of the document (tracking, click, error,
+// impression, ad tag, media, resource and verification URLs, those of the VAST
+// 3 AdVerifications extensions included) by the value returned by fn. It can
+// be used to expand macros across the whole document.
+func (v *VAST) RewriteURLs(fn func(u string) string) {
+	r := urlRewriter(fn)
+	r.cdatas(v.Errors)
+	for i := range v.Ads {
+		ad := &v.Ads[i]
+		if in := ad.InLine; in != nil {
+			r.impressions(in.Impressions)
+			r.cdatas(in.Errors)
+			r.cdata(&in.Survey)
+			for j := range in.Creatives {
+				r.creative(&in.Creatives[j])
+			}
+			if in.Extensions != nil {
+				r.extensions(*in.Extensions)
+			}
+			r.verifications(in.AdVerifications)
+		}
+		if w := ad.Wrapper; w != nil {
+			r.cdata(&w.VASTAdTagURI)
+			r.impressions(w.Impressions)
+			r.cdatas(w.Errors)
+			for j := range w.Creatives {
+				r.creativeWrapper(&w.Creatives[j])
+			}
+			r.extensions(w.Extensions)
+			r.verifications(w.AdVerifications)
+		}
+	}
+}
+
+type urlRewriter func(string) string
+
+func (r urlRewriter) uri(u *string) {
+	if *u != "" {
+		*u = r(*u)
+	}
+}
+
+func (r urlRewriter) cdata(c *CDATAString) {
+	r.uri(&c.CDATA)
+}
+
+func (r urlRewriter) cdatas(cs []CDATAString) {
+	for i := range cs {
+		r.uri(&cs[i].CDATA)
+	}
+}
+
+func (r urlRewriter) impressions(imps []Impression) {
+	for i := range imps {
+		r.uri(&imps[i].URI)
+	}
+}
+
+func (r urlRewriter) trackings(ts []Tracking) {
+	for i := range ts {
+		r.uri(&ts[i].URI)
+	}
+}
+
+func (r urlRewriter) videoClicks(vc *VideoClicks) {
+	if vc == nil {
+		return
+	}
+	for _, cs := range [][]VideoClick{vc.ClickThroughs, vc.ClickTrackings, vc.CustomClicks} {
+		for i := range cs {
+			r.uri(&cs[i].URI)
+		}
+	}
+}
+
+func (r urlRewriter) resources(s *StaticResource, iframe *CDATAString) {
+	if s != nil {
+		r.uri(&s.URI)
+	}
+	r.cdata(iframe)
+}
+
+func (r urlRewriter) icons(icons *Icons) {
+	if icons == nil {
+		return
+	}
+	for i := range icons.Icon {
+		icon := &icons.Icon[i]
+		r.cdata(&icon.IconClickThrough)
+		r.cdatas(icon.IconClickTrackings)
+		r.resources(icon.StaticResource, &icon.IFrameResource)
+	}
+}
+
+func (r urlRewriter) companions(cs []Companion) {
+	for i := range cs {
+		c := &cs[i]
+		r.cdata(&c.CompanionClickThrough)
+		r.cdatas(c.CompanionClickTracking)
+		r.trackings(c.TrackingEvents)
+		r.resources(c.StaticResource, &c.IFrameResource)
+	}
+}
+
+func (r urlRewriter) creative(c *Creative) {
+	if l := c.Linear; l != nil {
+		r.icons(l.Icons)
+		r.trackings(l.TrackingEvents)
+		r.videoClicks(l.VideoClicks)
+		for i := range l.MediaFiles {
+			r.uri(&l.MediaFiles[i].URI)
+		}
+		for i := range l.InteractiveCreativeFiles {
+			r.uri(&l.InteractiveCreativeFiles[i].URI)
+		}
+	}
+	if c.CompanionAds != nil {
+		r.companions(c.CompanionAds.Companions)
+	}
+	if nl := c.NonLinearAds; nl != nil {
+		r.trackings(nl.TrackingEvents)
+		for i := range nl.NonLinears {
+			n := &nl.NonLinears[i]
+			r.cdatas(n.NonLinearClickTracking)
+			r.cdata(&n.NonLinearClickThrough)
+			r.resources(n.StaticResource, &n.IFrameResource)
+		}
+	}
+	if c.CreativeExtensions != nil {
+		r.extensions(*c.CreativeExtensions)
+	}
+}
+
+func (r urlRewriter) creativeWrapper(c *CreativeWrapper) {
+	if l := c.Linear; l != nil {
+		r.icons(l.Icons)
+		r.trackings(l.TrackingEvents)
+		r.videoClicks(l.VideoClicks)
+	}
+	if c.CompanionAds != nil {
+		for i := range c.CompanionAds.Companions {
+			cw := &c.CompanionAds.Companions[i]
+			r.cdata(&cw.CompanionClickThrough)
+			r.cdatas(cw.CompanionClickTracking)
+			r.trackings(cw.TrackingEvents)
+			r.resources(cw.StaticResource, &cw.IFrameResource)
+		}
+	}
+	if nl := c.NonLinearAds; nl != nil {
+		r.trackings(nl.TrackingEvents)
+		for i := range nl.NonLinears {
+			r.trackings(nl.NonLinears[i].TrackingEvents)
+			r.cdatas(nl.NonLinears[i].NonLinearClickTracking)
+		}
+	}
+}
+
+func (r urlRewriter) extensions(exts []Extension) {
+	for i := range exts {
+		r.trackings(exts[i].CustomTracking)
+		r.verificationsExtension(&exts[i])
+	}
+}
+
+// verificationsExtension rewrites the URLs of the verifications of a VAST 3
+// AdVerifications extension. If a URL changes, the decoded verifications are
+// set as the value of the extension, to be encoded in place of its raw data.
+func (r urlRewriter) verificationsExtension(e *Extension) {
+	if e.Type != "AdVerifications" {
+		return
+	}
+	av, typed := e.Value.(*AdVerificationsExtension)
+	if !typed {
+		if e.Value != nil {
+			return
+		}
+		v, err := e.Decode()
+		if av, _ = v.(*AdVerificationsExtension); err != nil || av == nil {
+			return
+		}
+	}
+	changed := false
+	urlRewriter(func(u string) string {
+		n := r(u)
+		changed = changed || n != u
+		return n
+	}).verifications(av.Verifications)
+	if changed && !typed {
+		e.Value = av
+	}
+}
+
+func (r urlRewriter) verifications(vs []Verification) {
+	for i := range vs {
+		v := &vs[i]
+		for j := range v.JavaScriptResource {
+			r.uri(&v.JavaScriptResource[j].URI)
+		}
+		for j := range v.ExecutableResource {
+			r.uri(&v.ExecutableResource[j].URI)
+		}
+		r.trackings(v.TrackingEvents)
+	}
+}
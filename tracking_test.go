@@ -0,0 +1,81 @@
+package vast
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdTrackingURLs(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast_inline_linear.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ad := &v.Ads[0]
+	assert.Equal(t, []string{"http://myTrackingURL/impression", "http://myTrackingURL/impression2"}, ad.ImpressionURLs())
+	assert.Equal(t, []string{"http://myErrorURL/error", "http://myErrorURL/error2"}, ad.ErrorURLs())
+	assert.Equal(t, []string{"http://myTrackingURL/start"}, ad.TrackingURLs("start"))
+	assert.Equal(t, []string{"http://myTrackingURL/creativeView", "http://myTrackingURL/firstCompanionCreativeView"}, ad.TrackingURLs("creativeView"))
+	assert.Equal(t, []string{"http://myTrackingURL/click"}, ad.ClickTrackingURLs())
+	assert.Empty(t, ad.TrackingURLs("unknown"))
+}
+
+func TestWrapperTrackingURLs(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast_wrapper_linear_1.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ad := &v.Ads[0]
+	assert.Equal(t, []string{"http://myTrackingURL/wrapper/impression"}, ad.ImpressionURLs())
+	assert.Equal(t, []string{"http://myErrorURL/wrapper/error"}, ad.ErrorURLs())
+	assert.Equal(t, []string{"http://myTrackingURL/wrapper/click"}, ad.ClickTrackingURLs())
+	assert.Len(t, ad.TrackingURLs("creativeView"), 2)
+}
+
+func TestRewriteURLs(t *testing.T) {
+	for _, fixture := range []string{"testdata/vast_inline_linear.xml", "testdata/vast_wrapper_linear_1.xml", "testdata/vast_inline_nonlinear.xml", "testdata/inline_extensions.xml"} {
+		v, _, _, err := loadFixture(fixture)
+		if !assert.NoError(t, err) {
+			return
+		}
+		before := v.Clone()
+		n := 0
+		v.RewriteURLs(func(u string) string {
+			n++
+			return "rewritten:" + u
+		})
+		assert.NotZero(t, n, fixture)
+		// every difference must be a rewritten URL
+		diffs := Diff(before, v)
+		assert.Len(t, diffs, n, fixture)
+		for _, d := range diffs {
+			assert.Equal(t, "rewritten:"+d.A.(string), d.B, d.Path)
+		}
+	}
+
+	v, _, _, err := loadFixture("testdata/vast_wrapper_linear_1.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	v.RewriteURLs(func(u string) string { return Macros{"ERRORCODE": "301"}.Expand(u + "?e=[ERRORCODE]") })
+	assert.Equal(t, "http://myErrorURL/wrapper/error?e=301", v.Ads[0].Wrapper.Errors[0].CDATA)
+	assert.Equal(t, "http://demo.tremormedia.com/proddev/vast/vast_inline_linear.xml?e=301", v.Ads[0].Wrapper.VASTAdTagURI.CDATA)
+
+	// the verifications of the VAST 3 extension are rewritten as well
+	v, _, _, err = loadFixture("testdata/vast4_ad_verifications.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	v.RewriteURLs(func(u string) string { return u + "?x=1" })
+	b, err := xml.Marshal(v)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(b), `<JavaScriptResource apiFramework="omid"><![CDATA[https://js.moatads.com/moatvideo.js?x=1]]>`)
+	}
+	// extensions are left untouched if no URL changes
+	v, _, _, err = loadFixture("testdata/vast4_ad_verifications.xml")
+	if assert.NoError(t, err) {
+		v.RewriteURLs(func(u string) string { return u })
+		assert.Nil(t, (*v.Ads[0].InLine.Extensions)[0].Value)
+	}
+}
@@ -16,6 +16,10 @@ type VAST struct {
 	// Contains a URI to a tracking resource that the video player should request
 	// upon receiving a “no ad” response
 	Errors []CDATAString `xml:"Error,omitempty"`
+
+	// root is the name of the root element of a decoded document, VAST or
+	// DAAST, empty for documents built in code.
+	root xml.Name
 }
 
 // Ad represent an <Ad> child tag in a VAST document
@@ -27,9 +31,12 @@ type Ad struct {
 	// A number greater than zero (0) that identifies the sequence in which
 	// an ad should play; all <Ad> elements with sequence values are part of
 	// a pod and are intended to be played in sequence
-	Sequence int      `xml:"sequence,attr,omitempty"`
-	InLine   *InLine  `xml:",omitempty"`
-	Wrapper  *Wrapper `xml:",omitempty"`
+	Sequence int `xml:"sequence,attr,omitempty"`
+	// The type of the ad, "video", "audio" or "hybrid" (VAST 4.1). Video is
+	// assumed if empty.
+	AdType  string   `xml:"adType,attr,omitempty"`
+	InLine  *InLine  `xml:",omitempty"`
+	Wrapper *Wrapper `xml:",omitempty"`
 }
 
 // CDATAString ...
@@ -79,6 +86,20 @@ type InLine struct {
 	// XML elements from VAST elements. The following example includes a custom
 	// xml element within the Extensions element.
 	Extensions *[]Extension `xml:"Extensions>Extension,omitempty"`
+	// The container for zero or more <Verification> elements used to load
+	// third party measurement code (VAST 4.1 Open Measurement).
+	AdVerifications []Verification `xml:"AdVerifications>Verification,omitempty"`
+	// The number of seconds the ad can be cached (VAST 4).
+	Expires int `xml:",omitempty"`
+	// The categories of the ad content (VAST 4, DAAST).
+	Categories []Category `xml:"Category,omitempty"`
+}
+
+// Category is a category of the ad content, as a code of the taxonomy
+// identified by the authority URL.
+type Category struct {
+	Authority string `xml:"authority,attr,omitempty"`
+	Code      string `xml:",cdata"`
 }
 
 // Impression is a URI that directs the video player to a tracking resource file that
@@ -121,6 +142,10 @@ type Wrapper struct {
 	// A URI representing an error-tracking pixel; this element can occur multiple
 	// times.
 	Errors []CDATAString `xml:"Error,omitempty"`
+	// Provides a value that represents a price that can be used by real-time bidding
+	// (RTB) systems. Only the value offered in the first Wrapper of a chain need
+	// be considered.
+	Pricing *Pricing `xml:",omitempty"`
 	// The container for one or more <Creative> elements
 	Creatives []CreativeWrapper `xml:"Creatives>Creative"`
 	// XML node for custom extensions, as defined by the ad server. When used, a
@@ -128,6 +153,9 @@ type Wrapper struct {
 	// XML elements from VAST elements. The following example includes a custom
 	// xml element within the Extensions element.
 	Extensions []Extension `xml:"Extensions>Extension,omitempty"`
+	// The container for zero or more <Verification> elements used to load
+	// third party measurement code (VAST 4.1 Open Measurement).
+	AdVerifications []Verification `xml:"AdVerifications>Verification,omitempty"`
 
 	FallbackOnNoAd           *bool `xml:"fallbackOnNoAd,attr,omitempty"`
 	AllowMultipleAds         *bool `xml:"allowMultipleAds,attr,omitempty"`
@@ -239,15 +267,19 @@ type Linear struct {
 	// Duration in standard time format, hh:mm:ss
 	Duration       Duration
 	AdParameters   *AdParameters `xml:",omitempty"`
-	Icons          *Icons
-	TrackingEvents []Tracking   `xml:"TrackingEvents>Tracking,omitempty"`
-	VideoClicks    *VideoClicks `xml:",omitempty"`
-	MediaFiles     []MediaFile  `xml:"MediaFiles>MediaFile,omitempty"`
+	Icons          *Icons        `xml:",omitempty"`
+	TrackingEvents []Tracking    `xml:"TrackingEvents>Tracking,omitempty"`
+	VideoClicks    *VideoClicks  `xml:",omitempty"`
+	MediaFiles     []MediaFile   `xml:"MediaFiles>MediaFile,omitempty"`
+	// VAST 4.1 interactive files (SIMID) executed alongside the media file
+	InteractiveCreativeFiles []InteractiveCreativeFile `xml:"MediaFiles>InteractiveCreativeFile,omitempty"`
+	// VAST 4.1 closed caption files of the media file
+	ClosedCaptionFiles []ClosedCaptionFile `xml:"MediaFiles>ClosedCaptionFiles>ClosedCaptionFile,omitempty"`
 }
 
 // LinearWrapper defines a wrapped linear creative
 type LinearWrapper struct {
-	Icons          *Icons
+	Icons          *Icons       `xml:",omitempty"`
 	TrackingEvents []Tracking   `xml:"TrackingEvents>Tracking,omitempty"`
 	VideoClicks    *VideoClicks `xml:",omitempty"`
 }
@@ -297,17 +329,17 @@ type CompanionWrapper struct {
 	// Optional identifier
 	ID string `xml:"id,attr,omitempty"`
 	// Pixel dimensions of companion slot.
-	Width int `xml:"width,attr"`
+	Width int `xml:"width,attr,omitempty"`
 	// Pixel dimensions of companion slot.
-	Height int `xml:"height,attr"`
+	Height int `xml:"height,attr,omitempty"`
 	// Pixel dimensions of the companion asset.
-	AssetWidth int `xml:"assetWidth,attr"`
+	AssetWidth int `xml:"assetWidth,attr,omitempty"`
 	// Pixel dimensions of the companion asset.
-	AssetHeight int `xml:"assetHeight,attr"`
+	AssetHeight int `xml:"assetHeight,attr,omitempty"`
 	// Pixel dimensions of expanding companion ad when in expanded state.
-	ExpandedWidth int `xml:"expandedWidth,attr"`
+	ExpandedWidth int `xml:"expandedWidth,attr,omitempty"`
 	// Pixel dimensions of expanding companion ad when in expanded state.
-	ExpandedHeight int `xml:"expandedHeight,attr"`
+	ExpandedHeight int `xml:"expandedHeight,attr,omitempty"`
 	// The apiFramework defines the method to use for communication with the companion.
 	APIFramework string `xml:"apiFramework,attr,omitempty"`
 	// Used to match companion creative to publisher placement areas on the page.
@@ -519,6 +551,28 @@ type MediaFile struct {
 	URI          string `xml:",cdata"`
 }
 
+// ClosedCaptionFile defines a VAST 4.1 closed caption file of a linear
+// creative.
+type ClosedCaptionFile struct {
+	// MIME type of the file, such as "text/vtt" for WebVTT.
+	Type string `xml:"type,attr,omitempty"`
+	// Language of the captions, as a language tag such as "en" or "fr-CA".
+	Language string `xml:"language,attr,omitempty"`
+	URI      string `xml:",cdata"`
+}
+
+// InteractiveCreativeFile defines a VAST 4.1 interactive creative file, such as
+// a SIMID creative, executed alongside the media file.
+type InteractiveCreativeFile struct {
+	// MIME type of the file, typically "text/html" for SIMID.
+	Type string `xml:"type,attr,omitempty"`
+	// The API needed to execute the interactive media file (e.g. "SIMID").
+	APIFramework string `xml:"apiFramework,attr,omitempty"`
+	// Whether the interactive creative may change the duration of the ad.
+	VariableDuration bool   `xml:"variableDuration,attr,omitempty"`
+	URI              string `xml:",cdata"`
+}
+
 // UniversalAdID describes a VAST 4.x universal ad id.
 type UniversalAdID struct {
 	IDRegistry string `xml:"idRegistry,attr"`
@@ -0,0 +1,281 @@
+// Package vasttest provides an in-process VAST ad server for tests.
+//
+// A Server serves VAST documents or fixtures registered per path, can
+// simulate wrapper chains, empty responses, timeouts, redirects, malformed
+// XML and slow bodies, and records every request made to it so tests can
+// assert that impression, tracking or error URLs were fired.
+package vasttest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/vast"
+)
+
+// TrackPrefix is the path prefix of tracking URLs returned by TrackURL. Any
+// request under this prefix is answered with an empty 200 response.
+const TrackPrefix = "/track/"
+
+// Hit is a request received by the server.
+type Hit struct {
+	Method string
+	// URL is the requested URL, relative to the server root (path and query).
+	URL    string
+	Header http.Header
+	Time   time.Time
+}
+
+// Server is an in-process VAST ad server.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	routes map[string]http.HandlerFunc
+	hits   []Hit
+	done   chan struct{}
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		routes: map[string]http.HandlerFunc{},
+		done:   make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close unblocks pending timeout handlers and shuts down the server.
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+// URLFor returns the absolute URL of path on the server.
+func (s *Server) URLFor(path string) string {
+	return s.URL + path
+}
+
+// TrackURL returns the absolute URL of a tracking pixel named name. The name
+// may contain a query string or VAST macros, e.g. "error?code=[ERRORCODE]".
+func (s *Server) TrackURL(name string) string {
+	return s.URL + TrackPrefix + name
+}
+
+// Handle registers a custom handler for path.
+func (s *Server) Handle(path string, h http.HandlerFunc) {
+	s.mu.Lock()
+	s.routes[path] = h
+	s.mu.Unlock()
+}
+
+// HandleVAST serves the XML encoding of v on path.
+func (s *Server) HandleVAST(path string, v *vast.VAST) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("vasttest: cannot marshal VAST for %s: %v", path, err))
+	}
+	s.HandleRaw(path, append([]byte(xml.Header), b...))
+}
+
+// HandleFixture serves the content of the file at filename on path.
+func (s *Server) HandleFixture(path, filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	s.HandleRaw(path, b)
+	return nil
+}
+
+// HandleRaw serves body verbatim on path. It can be used to serve malformed
+// XML.
+func (s *Server) HandleRaw(path string, body []byte) {
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(body)
+	})
+}
+
+// HandleEmpty serves an empty VAST response, containing no ad and the given
+// error tracking URLs, on path.
+func (s *Server) HandleEmpty(path string, errorURLs ...string) {
+	s.HandleVAST(path, vast.NoAd("3.0", errorURLs...))
+}
+
+// HandleStatus serves an empty response with the given HTTP status code on
+// path.
+func (s *Server) HandleStatus(path string, code int) {
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	})
+}
+
+// HandleTimeout registers a handler that never responds on path. The request
+// is held until the client gives up or the server is closed.
+func (s *Server) HandleTimeout(path string) {
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-s.done:
+		}
+	})
+}
+
+// HandleRedirect redirects requests for path to target with the given 3xx
+// status code. The target may be a path on the server or an absolute URL.
+func (s *Server) HandleRedirect(path, target string, code int) {
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, code)
+	})
+}
+
+// HandleSlow serves the XML encoding of v on path, writing it in chunks of
+// chunkSize bytes with delay between each chunk. It panics if chunkSize is
+// less than 1.
+func (s *Server) HandleSlow(path string, v *vast.VAST, chunkSize int, delay time.Duration) {
+	if chunkSize < 1 {
+		panic(fmt.Sprintf("vasttest: invalid chunk size %d for %s", chunkSize, path))
+	}
+	b, err := xml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("vasttest: cannot marshal VAST for %s: %v", path, err))
+	}
+	s.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		body := b
+		for len(body) > 0 {
+			n := chunkSize
+			if n > len(body) {
+				n = len(body)
+			}
+			if _, err := w.Write(body[:n]); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			body = body[n:]
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+	})
+}
+
+// HandleWrapperChain serves on path the first of depth wrappers, each pointing
+// to the next one, the last wrapper pointing to final. The wrappers are served
+// on path + "/wrapper/1" to path + "/wrapper/<depth-1>", final is served on
+// path + "/inline". Each wrapper has an impression and an error tracker named
+// "wrapper/<n>/impression" and "wrapper/<n>/error?code=[ERRORCODE]", n
+// starting at 0. If depth is 0, final is served on path directly.
+func (s *Server) HandleWrapperChain(path string, depth int, final *vast.VAST) {
+	if depth == 0 {
+		s.HandleVAST(path, final)
+		return
+	}
+	p := path
+	for i := 0; i < depth; i++ {
+		next := fmt.Sprintf("%s/wrapper/%d", path, i+1)
+		if i == depth-1 {
+			next = path + "/inline"
+		}
+		s.HandleVAST(p, Wrapper(s.URLFor(next),
+			s.TrackURL(fmt.Sprintf("wrapper/%d/impression", i)),
+			s.TrackURL(fmt.Sprintf("wrapper/%d/error?code=[ERRORCODE]", i))))
+		p = next
+	}
+	s.HandleVAST(p, final)
+}
+
+// Wrapper returns a VAST document made of a single wrapper ad pointing to
+// adTagURI, with optional impression and error tracking URLs.
+func Wrapper(adTagURI, impression, errorURL string) *vast.VAST {
+	w := &vast.Wrapper{
+		AdSystem:     &vast.AdSystem{Name: "vasttest"},
+		VASTAdTagURI: vast.CDATAString{CDATA: adTagURI},
+	}
+	if impression != "" {
+		w.Impressions = []vast.Impression{{URI: impression}}
+	}
+	if errorURL != "" {
+		w.Errors = []vast.CDATAString{{CDATA: errorURL}}
+	}
+	return &vast.VAST{Version: "3.0", Ads: []vast.Ad{{ID: "vasttest-wrapper", Wrapper: w}}}
+}
+
+// Hits returns the requests received by the server so far, in order.
+func (s *Server) Hits() []Hit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Hit(nil), s.hits...)
+}
+
+// HitCount returns the number of requests received for u. The URL may be
+// absolute or relative to the server root. It must match the requested path
+// and query exactly.
+func (s *Server) HitCount(u string) int {
+	u = strings.TrimPrefix(u, s.URL)
+	if pu, err := url.Parse(u); err == nil {
+		u = pu.RequestURI()
+	}
+	n := 0
+	for _, h := range s.Hits() {
+		if h.URL == u {
+			n++
+		}
+	}
+	return n
+}
+
+// Fired reports whether u has been requested at least once.
+func (s *Server) Fired(u string) bool {
+	return s.HitCount(u) > 0
+}
+
+// Reset forgets about all the requests received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.hits = nil
+	s.mu.Unlock()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits = append(s.hits, Hit{
+		Method: r.Method,
+		URL:    r.URL.RequestURI(),
+		Header: r.Header,
+		Time:   time.Now(),
+	})
+	h := s.routes[r.URL.Path]
+	s.mu.Unlock()
+
+	switch {
+	case h != nil:
+		h(w, r)
+	case strings.HasPrefix(r.URL.Path, TrackPrefix):
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
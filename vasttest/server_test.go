@@ -0,0 +1,157 @@
+package vasttest
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rs/vast"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(c *http.Client, u string) (*http.Response, *vast.VAST, error) {
+	res, err := c.Get(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return res, nil, err
+	}
+	var v vast.VAST
+	return res, &v, xml.Unmarshal(b, &v)
+}
+
+func TestServerFixture(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	assert.NoError(t, s.HandleFixture("/inline", "../testdata/vast_inline_linear.xml"))
+	assert.Error(t, s.HandleFixture("/missing", "../testdata/missing.xml"))
+
+	res, v, err := get(http.DefaultClient, s.URLFor("/inline"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "2.0", v.Version)
+		assert.Len(t, v.Ads, 1)
+	}
+	assert.True(t, s.Fired("/inline"))
+	assert.False(t, s.Fired("/missing"))
+
+	res, err = http.Get(s.URLFor("/unknown"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		res.Body.Close()
+	}
+}
+
+func TestServerWrapperChain(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.HandleWrapperChain("/chain", 3, &vast.VAST{Version: "3.0", Ads: []vast.Ad{{ID: "final", InLine: &vast.InLine{}}}})
+	u := s.URLFor("/chain")
+	for i := 0; i < 3; i++ {
+		_, v, err := get(http.DefaultClient, u)
+		if !assert.NoError(t, err) || !assert.NotNil(t, v.Ads[0].Wrapper) {
+			return
+		}
+		w := v.Ads[0].Wrapper
+		assert.Equal(t, s.TrackURL("wrapper/"+strconv.Itoa(i)+"/impression"), w.Impressions[0].URI)
+		u = w.VASTAdTagURI.CDATA
+	}
+	assert.Equal(t, s.URLFor("/chain/inline"), u)
+	_, v, err := get(http.DefaultClient, u)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "final", v.Ads[0].ID)
+	}
+}
+
+func TestServerTracking(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	res, err := http.Get(s.TrackURL("error?code=303"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+	assert.True(t, s.Fired(s.TrackURL("error?code=303")))
+	assert.Equal(t, 1, s.HitCount("/track/error?code=303"))
+	assert.False(t, s.Fired(s.TrackURL("error?code=[ERRORCODE]")))
+	if hits := s.Hits(); assert.Len(t, hits, 1) {
+		assert.Equal(t, "GET", hits[0].Method)
+		assert.Equal(t, "/track/error?code=303", hits[0].URL)
+	}
+	s.Reset()
+	assert.Empty(t, s.Hits())
+}
+
+func TestServerEmptyAndMalformed(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.HandleEmpty("/empty")
+	s.HandleRaw("/malformed", []byte(`<VAST version="3.0"><Ad>`))
+	s.HandleStatus("/nocontent", http.StatusNoContent)
+
+	_, v, err := get(http.DefaultClient, s.URLFor("/empty"))
+	if assert.NoError(t, err) {
+		assert.Empty(t, v.Ads)
+	}
+	_, _, err = get(http.DefaultClient, s.URLFor("/malformed"))
+	assert.Error(t, err)
+	res, err := http.Get(s.URLFor("/nocontent"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		res.Body.Close()
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.HandleTimeout("/timeout")
+	c := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err := c.Get(s.URLFor("/timeout"))
+	assert.Error(t, err)
+}
+
+func TestServerRedirect(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.HandleEmpty("/target")
+	s.HandleRedirect("/redirect", "/target", http.StatusFound)
+	res, _, err := get(http.DefaultClient, s.URLFor("/redirect"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, s.URLFor("/target"), res.Request.URL.String())
+	}
+	assert.True(t, s.Fired("/redirect"))
+	assert.True(t, s.Fired("/target"))
+}
+
+func TestServerSlow(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.HandleSlow("/slow", &vast.VAST{Version: "3.0"}, 4, 5*time.Millisecond)
+	start := time.Now()
+	_, v, err := get(http.DefaultClient, s.URLFor("/slow"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.0", v.Version)
+	}
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	// the body is served in full on each request
+	_, v, err = get(http.DefaultClient, s.URLFor("/slow"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.0", v.Version)
+	}
+	assert.Panics(t, func() { s.HandleSlow("/slow/0", &vast.VAST{}, 0, 0) })
+}
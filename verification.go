@@ -0,0 +1,69 @@
+package vast
+
+// Verification contains the code and parameters needed to execute a third
+// party measurement script (IAB Open Measurement).
+type Verification struct {
+	// An identifier for the verification vendor.
+	Vendor string `xml:"vendor,attr,omitempty"`
+	// A container for the URI to the JavaScript file used to collect
+	// verification data.
+	JavaScriptResource []JavaScriptResource `xml:",omitempty"`
+	// A reference to a non-JavaScript or custom-integration resource
+	// intended for collecting verification data via the listed apiFramework.
+	ExecutableResource []ExecutableResource `xml:",omitempty"`
+	// The verificationNotExecuted event should be requested when the
+	// verification script was not executed.
+	TrackingEvents []Tracking `xml:"TrackingEvents>Tracking,omitempty"`
+	// CDATA-wrapped metadata string for the verification executable.
+	VerificationParameters *CDATAString `xml:",omitempty"`
+}
+
+// JavaScriptResource is the URI to a JavaScript verification script.
+type JavaScriptResource struct {
+	// Identifies the API needed to execute the resource file, such as "omid".
+	APIFramework string `xml:"apiFramework,attr,omitempty"`
+	// Whether the script can be executed in a browser-less environment.
+	BrowserOptional bool   `xml:"browserOptional,attr,omitempty"`
+	URI             string `xml:",cdata"`
+}
+
+// ExecutableResource is the URI to a non-JavaScript verification executable.
+type ExecutableResource struct {
+	// Identifies the API needed to execute the resource file.
+	APIFramework string `xml:"apiFramework,attr,omitempty"`
+	// The type of executable resource provided.
+	Type string `xml:"type,attr,omitempty"`
+	URI  string `xml:",cdata"`
+}
+
+// Verifications returns the verifications of the ad, whether they are given in
+// the VAST 4.1 <AdVerifications> element or using the VAST 3 <Extension
+// type="AdVerifications"> form.
+func (ad *Ad) Verifications() []Verification {
+	var vs []Verification
+	var exts []Extension
+	switch {
+	case ad.InLine != nil:
+		vs = append(vs, ad.InLine.AdVerifications...)
+		if ad.InLine.Extensions != nil {
+			exts = *ad.InLine.Extensions
+		}
+	case ad.Wrapper != nil:
+		vs = append(vs, ad.Wrapper.AdVerifications...)
+		exts = ad.Wrapper.Extensions
+	}
+	for _, ext := range exts {
+		if ext.Type != "AdVerifications" {
+			continue
+		}
+		// decode a copy so the ad is left untouched
+		v, err := ext.Decode()
+		if err != nil {
+			continue
+		}
+		if av, ok := v.(*AdVerificationsExtension); ok {
+			vs = append(vs, av.Verifications...)
+		}
+	}
+	return vs
+}
@@ -0,0 +1,64 @@
+package vast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdVerifications(t *testing.T) {
+	v, _, _, err := loadFixture("testdata/vast4_ad_verifications.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "4.1", v.Version)
+	if assert.Len(t, v.Ads, 1) {
+		ad := v.Ads[0]
+		if assert.NotNil(t, ad.InLine) && assert.Len(t, ad.InLine.AdVerifications, 2) {
+			ver := ad.InLine.AdVerifications[0]
+			assert.Equal(t, "company.com-omid", ver.Vendor)
+			if assert.Len(t, ver.JavaScriptResource, 1) {
+				assert.Equal(t, "omid", ver.JavaScriptResource[0].APIFramework)
+				assert.True(t, ver.JavaScriptResource[0].BrowserOptional)
+				assert.Equal(t, "https://verification.com/omid_verification.js", ver.JavaScriptResource[0].URI)
+			}
+			if assert.Len(t, ver.TrackingEvents, 1) {
+				assert.Equal(t, "verificationNotExecuted", ver.TrackingEvents[0].Event)
+				assert.Equal(t, "https://verification.com/trackingurl/[REASON]", ver.TrackingEvents[0].URI)
+			}
+			if assert.NotNil(t, ver.VerificationParameters) {
+				assert.Equal(t, "verification params key/value pairs", ver.VerificationParameters.CDATA)
+			}
+			ver = ad.InLine.AdVerifications[1]
+			if assert.Len(t, ver.ExecutableResource, 1) {
+				assert.Equal(t, "custom", ver.ExecutableResource[0].APIFramework)
+				assert.Equal(t, "application/x-custom", ver.ExecutableResource[0].Type)
+				assert.Equal(t, "https://verification.com/custom_verification.exe", ver.ExecutableResource[0].URI)
+			}
+		}
+
+		vs := ad.Verifications()
+		if assert.Len(t, vs, 3) {
+			assert.Equal(t, "company.com-omid", vs[0].Vendor)
+			assert.Equal(t, "company.com-exe", vs[1].Vendor)
+			assert.Equal(t, "moat.com-test", vs[2].Vendor)
+			assert.Equal(t, "https://js.moatads.com/moatvideo.js", vs[2].JavaScriptResource[0].URI)
+		}
+		// the extension is left untouched
+		assert.Nil(t, (*ad.InLine.Extensions)[0].Value)
+	}
+}
+
+func TestAdVerificationsWrapper(t *testing.T) {
+	ad := Ad{Wrapper: &Wrapper{
+		AdVerifications: []Verification{{Vendor: "a"}},
+		Extensions:      []Extension{{Type: "AdVerifications", Data: []byte(`<AdVerifications><Verification vendor="b"/></AdVerifications>`)}},
+	}}
+	vs := ad.Verifications()
+	if assert.Len(t, vs, 2) {
+		assert.Equal(t, "a", vs[0].Vendor)
+		assert.Equal(t, "b", vs[1].Vendor)
+	}
+	assert.Empty(t, (&Ad{}).Verifications())
+}